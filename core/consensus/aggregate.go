@@ -0,0 +1,85 @@
+package consensus
+
+import (
+	"fmt"
+	protocol "gossip-price/core/gossip"
+	"gossip-price/core/price"
+	"math"
+	"sort"
+)
+
+// Aggregation methods
+const (
+	AggregateMedian      = "median"
+	AggregateTrimmedMean = "trimmed_mean"
+)
+
+// Aggregation computes the price of a message from the prices observed by
+// its signers.
+type Aggregation struct {
+	// Method is AggregateMedian or AggregateTrimmedMean.
+	Method string
+	// Trim is the fraction of the observations dropped from both ends by the
+	// trimmed mean, e.g. 0.2 drops the lowest and the highest 20%.
+	Trim float64
+	// MaxSpread is the maximum deviation of an observation from the median
+	// of all observations, in basis points. Zero disables the check.
+	MaxSpread int
+}
+
+// Validate returns an error if the aggregation is not valid.
+func (a Aggregation) Validate() error {
+	switch a.Method {
+	case AggregateMedian, AggregateTrimmedMean:
+	default:
+		return fmt.Errorf("consensus error, unknown aggregation method: %q", a.Method)
+	}
+	if a.Trim < 0 || a.Trim >= 0.5 {
+		return fmt.Errorf("consensus error, trim must be in the [0, 0.5) range: %v", a.Trim)
+	}
+	if a.MaxSpread < 0 {
+		return fmt.Errorf("consensus error, negative max spread: %d", a.MaxSpread)
+	}
+	return nil
+}
+
+// Filter splits the observations into the accepted ones and the ones which
+// deviate from the median of all observations by more than MaxSpread.
+func (a Aggregation) Filter(msgs []protocol.ProtocolMessage) ([]protocol.ProtocolMessage, []protocol.ProtocolMessage) {
+	if a.MaxSpread == 0 || len(msgs) == 0 {
+		return msgs, nil
+	}
+	prices := make([]float64, 0, len(msgs))
+	for _, msg := range msgs {
+		prices = append(prices, msg.Price)
+	}
+	median := price.Median(prices)
+
+	var accepted, rejected []protocol.ProtocolMessage
+	for _, msg := range msgs {
+		if median > 0 && math.Abs(msg.Price-median)/median*10000 > float64(a.MaxSpread) {
+			rejected = append(rejected, msg)
+			continue
+		}
+		accepted = append(accepted, msg)
+	}
+	return accepted, rejected
+}
+
+// Price returns the aggregated price of the observations.
+func (a Aggregation) Price(prices []float64) float64 {
+	if a.Method != AggregateTrimmedMean || len(prices) == 0 {
+		return price.Median(prices)
+	}
+	sorted := make([]float64, len(prices))
+	copy(sorted, prices)
+	sort.Float64s(sorted)
+
+	trim := int(float64(len(sorted)) * a.Trim)
+	sorted = sorted[trim : len(sorted)-trim]
+	sum := 0.0
+	for _, p := range sorted {
+		sum += p
+	}
+	return sum / float64(len(sorted))
+}
@@ -0,0 +1,134 @@
+package consensus
+
+import (
+	protocol "gossip-price/core/gossip"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAggregationValidate(t *testing.T) {
+	tests := []struct {
+		a   Aggregation
+		err bool
+	}{
+		{Aggregation{Method: AggregateMedian}, false},
+		{Aggregation{Method: AggregateTrimmedMean, Trim: 0.2, MaxSpread: 500}, false},
+		{Aggregation{Method: "mean"}, true},
+		{Aggregation{}, true},
+		{Aggregation{Method: AggregateTrimmedMean, Trim: -0.1}, true},
+		{Aggregation{Method: AggregateTrimmedMean, Trim: 0.5}, true},
+		{Aggregation{Method: AggregateMedian, MaxSpread: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.a.Validate(); (err != nil) != tt.err {
+			t.Errorf("%+v.Validate() error = %v, want error %t", tt.a, err, tt.err)
+		}
+	}
+}
+
+func TestAggregationPrice(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      Aggregation
+		prices []float64
+		want   float64
+	}{
+		{"median odd", Aggregation{Method: AggregateMedian}, []float64{3, 1, 2}, 2},
+		{"median even", Aggregation{Method: AggregateMedian}, []float64{4, 1, 3, 2}, 2.5},
+		{"median single", Aggregation{Method: AggregateMedian}, []float64{7}, 7},
+		{"trimmed mean", Aggregation{Method: AggregateTrimmedMean, Trim: 0.2}, []float64{100, 1, 2, 3, 4}, 3},
+		{"trimmed mean without trim", Aggregation{Method: AggregateTrimmedMean}, []float64{1, 2, 9}, 4},
+		// Less than one observation to drop from each end
+		{"trimmed mean small", Aggregation{Method: AggregateTrimmedMean, Trim: 0.2}, []float64{1, 2, 6}, 3},
+		{"trimmed mean drops both ends", Aggregation{Method: AggregateTrimmedMean, Trim: 0.25}, []float64{0.5, 2, 4, 1000}, 3},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Price(tt.prices); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Price(%v) = %v, want %v", tt.name, tt.prices, got, tt.want)
+		}
+	}
+
+	// The observations are not reordered
+	prices := []float64{3, 1, 2}
+	Aggregation{Method: AggregateTrimmedMean, Trim: 0.4}.Price(prices)
+	if prices[0] != 3 || prices[1] != 1 || prices[2] != 2 {
+		t.Errorf("Price() modified the observations: %v", prices)
+	}
+}
+
+func TestAggregationFilter(t *testing.T) {
+	signed := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		maxSpread int
+		prices    []float64
+		accepted  int
+		rejected  []float64
+	}{
+		{"disabled", 0, []float64{1000, 2000, 3000}, 3, nil},
+		// 2% from the median of 2000
+		{"within spread", 200, []float64{1960, 2000, 2040}, 3, nil},
+		{"beyond spread", 200, []float64{1959, 2000, 2041, 2000}, 2, []float64{1959, 2041}},
+		{"outlier", 100, []float64{2000, 2001, 2002, 3000, 2000}, 4, []float64{3000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msgs []protocol.ProtocolMessage
+			for i, price := range tt.prices {
+				msgs = append(msgs, observation(byte(i+1), "ETH-USD-1", price, signed))
+			}
+			accepted, rejected := Aggregation{Method: AggregateMedian, MaxSpread: tt.maxSpread}.Filter(msgs)
+			if len(accepted) != tt.accepted || len(rejected) != len(tt.rejected) {
+				t.Fatalf("Filter(%v) = %d accepted, %d rejected, want %d, %d", tt.prices, len(accepted), len(rejected), tt.accepted, len(tt.rejected))
+			}
+			for i, msg := range rejected {
+				if msg.Price != tt.rejected[i] {
+					t.Errorf("Filter(%v) rejected %v, want %v", tt.prices, msg.Price, tt.rejected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEngineRejectsObservationsBeyondSpread(t *testing.T) {
+	m := newTestEngine(t, Config{Aggregation: Aggregation{Method: AggregateMedian, MaxSpread: 100}})
+	var finalized []FinalizedRate
+	m.AddFinalizedHandler(func(rate FinalizedRate) { finalized = append(finalized, rate) })
+
+	// The outlier reaches the quorum, but it does not count toward it once
+	// it's rejected
+	id := RoundID(testPair, 1)
+	signed := time.Now().Add(-time.Minute)
+	for i, price := range []float64{2000, 2010, 2500} {
+		m.Append(observation(byte(i+1), id, price, signed))
+	}
+	finalizeAll(m)
+	if len(finalized) != 0 || m.Database().ExistCheck(id) {
+		t.Fatal("the rate is stored with an observation beyond the max spread")
+	}
+
+	m.Append(observation(4, id, 2006, signed))
+	finalizeAll(m)
+	if len(finalized) != 1 || finalized[0].Price != 2006 || len(finalized[0].Messages) != 3 {
+		t.Fatalf("finalized = %+v, want one rate at 2006 with 3 messages", finalized)
+	}
+	rate, err := m.Database().GetRate(id)
+	if err != nil {
+		t.Fatalf("GetRate() error: %v", err)
+	}
+	// Every accepted signer is stored with its own observation
+	want := map[string]string{
+		observation(1, id, 0, signed).Signer.String(): "2000",
+		observation(2, id, 0, signed).Signer.String(): "2010",
+		observation(4, id, 0, signed).Signer.String(): "2006",
+	}
+	if len(rate.Signatures) != len(want) {
+		t.Fatalf("GetRate() signatures = %+v, want %v", rate.Signatures, want)
+	}
+	for _, sig := range rate.Signatures {
+		if want[sig.Signer] != sig.Price {
+			t.Errorf("GetRate() observation of %s = %s, want %s", sig.Signer, sig.Price, want[sig.Signer])
+		}
+	}
+}
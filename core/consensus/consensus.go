@@ -2,37 +2,153 @@ package consensus
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"gossip-price/core/consensus/db"
 	"gossip-price/core/global"
 	protocol "gossip-price/core/gossip"
+	"gossip-price/core/metrics"
+	"gossip-price/core/registry"
+	"log"
 	"strconv"
-	"sync"
 	"time"
 )
 
+const (
+	// finalizationDelay is the time since the last signature of a message
+	// before it's stored, so late signatures are stored with it.
+	finalizationDelay = 30 * time.Second
+	// verifyInterval is the period of the engine loop.
+	verifyInterval = 30 * time.Second
+	// minPendingTTL is the shortest pending ttl. A message which reached the
+	// quorum waits for the finalization delay, and then for the next loop,
+	// so a shorter ttl drops it before it's stored.
+	minPendingTTL = finalizationDelay + verifyInterval
+)
+
+// FinalizedRate is a rate that reached the quorum and was stored. Price is
+// the aggregated price of the observations of Messages, observations
+// rejected by the aggregation are not included.
+type FinalizedRate struct {
+	MsgId    string
+	Pair     string
+	Price    float64
+	Trigger  string
+	Messages []protocol.ProtocolMessage
+}
+
+// FinalizedHandler is called for every finalized rate. Handlers are called
+// from the engine loop, so they must not block.
+type FinalizedHandler func(rate FinalizedRate)
+
+// PendingMessage is a message which is collecting signatures in the
+// engine and which is not stored yet.
+type PendingMessage struct {
+	MsgId       string
+	Pair        string
+	FirstSigner common.Address
+	StartedAt   time.Time
+	Messages    []protocol.ProtocolMessage
+}
+
+// Config is the configuration of the consensus engine.
+type Config struct {
+	// Pairs is the list of pairs the engine collects signatures for.
+	Pairs []string
+	// Database is the repository the finalized rates are stored to.
+	Database db.RateRepository
+	// Signers is the registry of authorized signers. If not nil, only the
+	// signatures of the authorized signers count toward the quorum.
+	Signers *registry.Registry
+	// Aggregation computes the price of the finalized rates.
+	Aggregation Aggregation
+	// PendingTTL is the time after which messages which are not stored are
+	// dropped. It must be at least a minute, the time a message takes to
+	// be stored after its last signature.
+	PendingTTL time.Duration
+	// MaxPending is the maximum number of messages collecting signatures.
+	// When it's reached, the oldest message which did not reach the quorum
+	// is dropped. Zero means no limit.
+	MaxPending int
+	// WAL is the log the pending messages are written to. If not nil, it's
+	// replayed by NewEngine.
+	WAL *WAL
+}
+
 type Engine struct {
-	ctx           context.Context
-	database      *db.Database
-	signerStarter map[string]common.Address
-	data          map[string][]protocol.ProtocolMessage
-	verifiedData  []protocol.ProtocolMessage
-	verifiedMutex sync.Mutex
+	ctx         context.Context
+	database    db.RateRepository
+	signers     *registry.Registry
+	aggregation Aggregation
+	store       *store
+	wal         *WAL
+	handlers    []FinalizedHandler
 }
 
 // New returns a new consensus engine of protocol with engine data
-func NewEngine() *Engine {
-	dbTmp := db.NewDatabase()
-	if dbTmp == nil {
-		return nil
+// for every configured pair, which stores the finalized rates to database.
+func NewEngine(c Config) (*Engine, error) {
+	if err := c.Aggregation.Validate(); err != nil {
+		return nil, err
+	}
+	// Rates without signatures cannot be verified by anyone
+	if global.GPMinimumSignerCount < 1 {
+		return nil, fmt.Errorf("consensus error, minimum signer count must be at least 1: %d", global.GPMinimumSignerCount)
+	}
+	// The ttl also bounds the stored message ids remembered against late
+	// signatures, so there is no option to keep the messages forever
+	if c.PendingTTL < minPendingTTL {
+		return nil, fmt.Errorf("consensus error, pending ttl must be at least %s: %s", minPendingTTL, c.PendingTTL)
+	}
+	m := &Engine{
+		database:    c.Database,
+		signers:     c.Signers,
+		aggregation: c.Aggregation,
+		store:       newStore(c.Pairs, c.PendingTTL, c.MaxPending),
+		wal:         c.WAL,
+	}
+	if m.wal != nil {
+		if err := m.replay(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// replay restores the pending messages from the WAL. Messages which
+// reached the quorum before the node stopped are marked as verified again,
+// so they are stored by the engine loop.
+func (m *Engine) replay() error {
+	records, err := m.wal.records()
+	if err != nil {
+		return fmt.Errorf("consensus error, unable to read WAL: %w", err)
+	}
+	for _, rec := range records {
+		switch rec.Op {
+		case walAdd:
+			if rec.Message != nil {
+				m.add(*rec.Message, rec.Time)
+			}
+		case walFinalize:
+			m.store.finalize(rec.Pair, rec.MsgId, rec.Time)
+		case walDrop:
+			m.store.remove(rec.Pair, rec.MsgId)
+		}
 	}
-	return &Engine{
-		database:      dbTmp,
-		data:          make(map[string][]protocol.ProtocolMessage),
-		signerStarter: make(map[string]common.Address),
-		verifiedData:  make([]protocol.ProtocolMessage, 0),
+	pending := m.store.snapshot("", false)
+	if len(pending) > 0 {
+		log.Printf("Replayed %d pending messages from WAL", len(pending))
 	}
+	if err := m.wal.compact(func() []PendingMessage { return pending }); err != nil {
+		return fmt.Errorf("consensus error, unable to compact WAL: %w", err)
+	}
+	return nil
+}
+
+// AddFinalizedHandler registers a handler which is called after a rate is
+// stored. Handlers must be added before the engine is started.
+func (m *Engine) AddFinalizedHandler(handler FinalizedHandler) {
+	m.handlers = append(m.handlers, handler)
 }
 
 // Start verify engine with context
@@ -41,108 +157,289 @@ func (m *Engine) StartEngine(ctx context.Context) {
 	go m.VerifyMessage()
 }
 
+// Database returns the database the engine stores the rates to
+func (m *Engine) Database() db.RateRepository {
+	return m.database
+}
+
+// Pending returns a snapshot of the messages of the pair which are not
+// stored yet. If pair is empty, messages of all pairs are returned.
+func (m *Engine) Pending(pair string) []PendingMessage {
+	return m.store.snapshot(pair, false)
+}
+
 // Return the count of current signed
-func (m *Engine) GetSignedCount(msgId string) int {
-	val, ok := m.data[msgId]
-	if !ok || len(val) == 0 {
-		return 0
-	}
-	return len(val)
+func (m *Engine) GetSignedCount(pair string, msgId string) int {
+	return m.store.count(pair, msgId)
 }
 
 // Check current signer already signed or not
 // Return true if current signer already signed
 // Return false if it's not signed yet
-func (m *Engine) CheckAlreadySigned(msgId string, currentSigner common.Address) bool {
-	val, ok := m.data[msgId]
-	if !ok || len(val) == 0 {
-		return false
-	}
-	// Check if current signer is existed in the list
-	for _, d := range val {
-		if currentSigner == d.Signer {
-			return true
-		}
-	}
-	return false
+func (m *Engine) CheckAlreadySigned(pair string, msgId string, currentSigner common.Address) bool {
+	return m.store.hasSigned(pair, msgId, currentSigner)
 }
 
 // Append signed message to cache memory and if the
 // signed count is bigger than GPMinimumSignerCount
-// then register the msgId to verified Data list
+// then mark the message as verified. It returns true
+// if the message is added and it needs more signatures.
+// Messages of not configured pairs, of unknown signers,
+// of already stored messages and duplicated signatures
+// are ignored.
 func (m *Engine) Append(message protocol.ProtocolMessage) bool {
-	_, ok := m.data[message.MsgId]
+	now := time.Now()
+	added, needMore := m.add(message, now)
+	if added {
+		m.writeWAL(walRecord{Op: walAdd, Time: now, Pair: message.Pair, MsgId: message.MsgId, Message: &message})
+	}
+	return needMore
+}
+
+// add appends the message to the store and marks it as verified if it
+// reached the quorum. It returns whether the message was added, and whether
+// it needs more signatures.
+func (m *Engine) add(message protocol.ProtocolMessage, now time.Time) (bool, bool) {
+	if m.signers != nil && !m.signers.IsAuthorizedAt(message.Signer, message.SignedTime) {
+		log.Printf("Discarded message(%s) of %s: %v", message.MsgId, message.Signer, global.ErrUnknownSigner)
+		return false, false
+	}
+	msgs, startedAt, ok := m.store.add(message, now)
 	if !ok {
-		// When it's first signer, allocate array and set signer as first
-		m.data[message.MsgId] = make([]protocol.ProtocolMessage, 0)
-		m.signerStarter[message.MsgId] = message.Signer
+		return false, false
 	}
+	metrics.PendingMessages.WithLabelValues(message.Pair).Set(float64(m.store.size(message.Pair)))
 
-	m.data[message.MsgId] = append(m.data[message.MsgId], message)
+	signedCount := m.quorumCount(msgs)
+	if signedCount == global.GPMinimumSignerCount {
+		metrics.TimeToQuorum.WithLabelValues(message.Pair).Observe(now.Sub(startedAt).Seconds())
+	}
 	// Check if the signed counts is bigger than minimum count
-	if len(m.data[message.MsgId]) >= global.GPMinimumSignerCount {
-		// Lock/Unlock verified to make no change itself while verify the message below function
-		m.verifiedMutex.Lock()
-		m.verifiedData = append(m.verifiedData, message)
-		m.verifiedMutex.Unlock()
-		return false
+	if signedCount >= global.GPMinimumSignerCount {
+		m.store.markVerified(message.Pair, message.MsgId)
+		return true, false
 	}
-	return true
+	return true, true
 }
 
-// Every 30 seconds it will check the verified list
-// and register to Postgre database if it passed 30 seconds
-// from the last signed time
+// writeWAL appends the record to the WAL, if the engine has one. Failed
+// writes are logged, the message is still kept in memory.
+func (m *Engine) writeWAL(rec walRecord) {
+	if m.wal == nil {
+		return
+	}
+	if err := m.wal.append(rec); err != nil {
+		log.Printf("Writing %s record of message(%s) to WAL failed: %v", rec.Op, rec.MsgId, err)
+	}
+}
+
+// Every 30 seconds it will check the verified messages
+// and register to database if it passed 30 seconds
+// from the last signed time. Every message is stored
+// once, with the aggregated price of the observations
+// of its signers. Observations beyond the max spread
+// are rejected and do not count toward the quorum.
+// Stored messages and messages older than the pending
+// ttl are removed from the engine.
 func (m *Engine) VerifyMessage() {
 	for {
 		select {
 		case <-m.ctx.Done():
-			break
-		case <-time.After(time.Second * 30): //todo
-			m.verifiedMutex.Lock()
-			cuTime := time.Now().Unix()
-			// Create temp data to keep remaining data and remove other ones
-			remainData := make([]protocol.ProtocolMessage, 0)
-			for _, val := range m.verifiedData {
-				lastTime := val.SignedTime.Unix()
-				// Check the time if it passed 30 seconds from last signed  time
-				if cuTime-lastTime < 30 { //todo
-					// If it's not passed 30 seconds it will move to remain list
-					remainData = append(remainData, val)
-				} else {
-					// If it's passed 30 seconds it will store to database
-					existed := m.database.ExistCheck(val.MsgId)
-					if existed {
-						continue
-					}
-					msgData, _ := m.data[val.MsgId]
-					signData := map[string]string{
-						"first_Signer":     msgData[0].Signer.String(),
-						"first_Signature":  msgData[0].Signature.String(),
-						"second_Signer":    msgData[1].Signer.String(),
-						"second_Signature": msgData[1].Signature.String(),
-						"third_Signer":     msgData[2].Signer.String(),
-						"third_Signature":  msgData[2].Signature.String(),
-					}
-					jsonData, err := json.Marshal(signData)
-					_, err = m.database.CreateRate(&db.Rate{
-						ID:              val.MsgId,
-						Price:           strconv.FormatFloat(val.Price, 'f', 2, 64),
-						First_Signer:    m.signerStarter[val.MsgId].String(),
-						Sign_Data:       string(jsonData),
-						LastSigned_Time: val.SignedTime,
-						Created_Time:    time.Now(),
-					})
-					// If writing database is failed then it will move to remain list as well
-					if err != nil {
-						remainData = append(remainData, val)
-					}
+			return
+		case <-time.After(verifyInterval): //todo
+			for _, pending := range m.store.snapshot("", true) {
+				m.finalize(pending)
+			}
+			for _, pending := range m.store.evict(time.Now()) {
+				log.Printf("Dropped message(%s) of %s with %d signatures, it's not stored in time", pending.MsgId, pending.Pair, len(pending.Messages))
+				m.writeWAL(walRecord{Op: walDrop, Time: time.Now(), Pair: pending.Pair, MsgId: pending.MsgId})
+			}
+			if m.wal != nil {
+				err := m.wal.compact(func() []PendingMessage { return m.store.snapshot("", false) })
+				if err != nil {
+					log.Printf("Compacting WAL failed: %v", err)
 				}
 			}
-			// Replace the remain list to verified data to verify next time and unlock
-			m.verifiedData = remainData
-			m.verifiedMutex.Unlock()
-			break
+			for _, pair := range m.pairs() {
+				metrics.PendingMessages.WithLabelValues(pair).Set(float64(m.store.size(pair)))
+			}
+		}
+	}
+}
+
+// finalize stores the verified message if it passed 30 seconds from its
+// last signed time, and calls the finalized handlers.
+func (m *Engine) finalize(pending PendingMessage) {
+	if m.database.ExistCheck(pending.MsgId) {
+		m.store.finalize(pending.Pair, pending.MsgId, time.Now())
+		m.writeWAL(walRecord{Op: walFinalize, Time: time.Now(), Pair: pending.Pair, MsgId: pending.MsgId})
+		return
+	}
+	msgData := m.authorized(pending.Messages)
+	var lastSigned time.Time
+	for _, msg := range msgData {
+		if msg.SignedTime.After(lastSigned) {
+			lastSigned = msg.SignedTime
+		}
+	}
+	// Check the time if it passed 30 seconds from last signed time,
+	// otherwise it's kept for the next time
+	if time.Since(lastSigned) < finalizationDelay { //todo
+		return
+	}
+	msgData, rejected := m.aggregation.Filter(msgData)
+	if len(msgData) < global.GPMinimumSignerCount {
+		return
+	}
+	stored, aggregated := m.newRate(pending.MsgId, pending.Pair, msgData)
+	trigger := roundTrigger(msgData, pending.FirstSigner)
+	stored.First_Signer = pending.FirstSigner.String()
+	stored.Trigger = trigger
+	_, err := m.database.CreateRate(stored)
+	// If writing database is failed then it's kept for the next time as well
+	if err != nil {
+		metrics.DBInsertErrors.Inc()
+		return
+	}
+	m.store.finalize(pending.Pair, pending.MsgId, time.Now())
+	m.writeWAL(walRecord{Op: walFinalize, Time: time.Now(), Pair: pending.Pair, MsgId: pending.MsgId})
+	for _, msg := range rejected {
+		log.Printf("Rejected observation %v of %s for message(%s), aggregated price is %v", msg.Price, msg.Signer, pending.MsgId, aggregated)
+	}
+	metrics.ObservationsRejected.WithLabelValues(pending.Pair).Add(float64(len(rejected)))
+	metrics.SignaturesPerMessage.WithLabelValues(pending.Pair).Observe(float64(len(msgData)))
+	metrics.TimeToFinalization.WithLabelValues(pending.Pair).Observe(time.Since(pending.StartedAt).Seconds())
+	rate := FinalizedRate{
+		MsgId:    pending.MsgId,
+		Pair:     pending.Pair,
+		Price:    aggregated,
+		Trigger:  trigger,
+		Messages: msgData,
+	}
+	for _, handler := range m.handlers {
+		handler(rate)
+	}
+}
+
+// Import stores a rate finalized by another node, e.g. received by the
+// state sync. The signatures of the messages must be verified by the
+// caller. The rate is built like the rates finalized by the engine: only
+// the authorized signers count, once each, the observations beyond the max
+// spread are rejected and the price is aggregated from the accepted ones,
+// so the other node cannot choose them. The last signed time of the other
+// node must be the time of the latest accepted signature. The finalized
+// handlers are not called.
+func (m *Engine) Import(msgId, pair, trigger string, lastSigned time.Time, msgs []protocol.ProtocolMessage) (*db.Rate, error) {
+	msgs, _ = m.aggregation.Filter(m.authorized(msgs))
+	if len(msgs) < global.GPMinimumSignerCount {
+		return nil, fmt.Errorf("consensus error, only %d valid signatures of rate %s", len(msgs), msgId)
+	}
+	rate, _ := m.newRate(msgId, pair, msgs)
+	if !rate.LastSigned_Time.Equal(lastSigned) {
+		return nil, fmt.Errorf("consensus error, rate %s is last signed at %s, its latest signature at %s", msgId, lastSigned, rate.LastSigned_Time)
+	}
+	first := msgs[0]
+	for _, msg := range msgs[1:] {
+		if msg.SignedTime.Before(first.SignedTime) {
+			first = msg
+		}
+	}
+	rate.First_Signer = first.Signer.String()
+	rate.Trigger = trigger
+	if _, err := m.database.CreateRate(rate); err != nil {
+		return nil, err
+	}
+	m.store.finalize(pair, msgId, time.Now())
+	m.writeWAL(walRecord{Op: walFinalize, Time: time.Now(), Pair: pair, MsgId: msgId})
+	return rate, nil
+}
+
+// newRate returns the rate of the accepted messages and its aggregated
+// price. The rate is last signed at the time of the latest accepted
+// signature.
+func (m *Engine) newRate(msgId, pair string, msgs []protocol.ProtocolMessage) (*db.Rate, float64) {
+	prices := make([]float64, 0, len(msgs))
+	signatures := make([]db.SignatureData, 0, len(msgs))
+	var lastSigned time.Time
+	for _, msg := range msgs {
+		prices = append(prices, msg.Price)
+		// Every accepted signature is stored with its observation,
+		// whatever the quorum size is
+		signatures = append(signatures, db.SignatureData{
+			Signer:     msg.Signer.String(),
+			Signature:  msg.Signature.String(),
+			Scheme:     msg.Scheme,
+			Price:      strconv.FormatFloat(msg.Price, 'f', -1, 64),
+			SignedTime: msg.SignedTime,
+		})
+		if msg.SignedTime.After(lastSigned) {
+			lastSigned = msg.SignedTime
+		}
+	}
+	aggregated := m.aggregation.Price(prices)
+	return &db.Rate{
+		ID:              msgId,
+		Pair:            pair,
+		Price:           strconv.FormatFloat(aggregated, 'f', -1, 64),
+		Signatures:      signatures,
+		LastSigned_Time: lastSigned,
+		Created_Time:    time.Now(),
+	}, aggregated
+}
+
+// pairs returns the configured pairs.
+func (m *Engine) pairs() []string {
+	pairs := make([]string, 0, len(m.store.pairs))
+	for pair := range m.store.pairs {
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// quorumCount returns the number of signatures which count toward the
+// quorum. Signers removed from the registry after their message was
+// appended are not counted anymore, and a signer which rotated its key
+// during the round is counted once.
+func (m *Engine) quorumCount(msgs []protocol.ProtocolMessage) int {
+	return len(m.authorized(msgs))
+}
+
+// authorized returns a copy of the messages of the authorized signers. Only
+// the first message of a signer is kept if it signed with several keys, or
+// of an address without signer registry.
+func (m *Engine) authorized(msgs []protocol.ProtocolMessage) []protocol.ProtocolMessage {
+	res := make([]protocol.ProtocolMessage, 0, len(msgs))
+	seen := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		id := msg.Signer.Hex()
+		if m.signers != nil {
+			s, ok := m.signers.SignerAt(msg.Signer, msg.SignedTime)
+			if !ok {
+				continue
+			}
+			id = s.Identity()
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		res = append(res, msg)
+	}
+	return res
+}
+
+// roundTrigger returns the trigger of the message of the first signer,
+// which started the round. If it's not known, the first known trigger of
+// the other signers is returned.
+func roundTrigger(msgs []protocol.ProtocolMessage, firstSigner common.Address) string {
+	trigger := ""
+	for _, msg := range msgs {
+		if msg.Signer == firstSigner && msg.Trigger != "" {
+			return msg.Trigger
+		}
+		if trigger == "" {
+			trigger = msg.Trigger
 		}
 	}
+	return trigger
 }
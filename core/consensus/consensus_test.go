@@ -0,0 +1,229 @@
+package consensus
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"gossip-price/core/consensus/db"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"gossip-price/core/registry"
+	"testing"
+	"time"
+)
+
+const testPair = "ETH/USD"
+
+// newTestEngine returns an engine of the test pair with a memory database,
+// which finalizes the messages signed by 3 signers. The config fields which
+// are not set are defaulted.
+func newTestEngine(t *testing.T, c Config) *Engine {
+	t.Helper()
+	prev := global.GPMinimumSignerCount
+	global.GPMinimumSignerCount = 3
+	t.Cleanup(func() { global.GPMinimumSignerCount = prev })
+
+	if c.Pairs == nil {
+		c.Pairs = []string{testPair}
+	}
+	if c.Database == nil {
+		c.Database = db.NewMemory()
+	}
+	if c.Aggregation.Method == "" {
+		c.Aggregation.Method = AggregateMedian
+	}
+	if c.PendingTTL == 0 {
+		c.PendingTTL = 10 * time.Minute
+	}
+	m, err := NewEngine(c)
+	if err != nil {
+		t.Fatalf("NewEngine() error: %v", err)
+	}
+	return m
+}
+
+// observation returns the message of the signer numbered n. Signatures are
+// verified before the messages reach the engine, so it's not signed.
+func observation(n byte, msgId string, price float64, signedTime time.Time) protocol.ProtocolMessage {
+	return protocol.ProtocolMessage{
+		Version:    protocol.SigningVersion,
+		MsgId:      msgId,
+		Pair:       testPair,
+		Price:      price,
+		Signer:     common.BytesToAddress([]byte{n}),
+		SignedTime: signedTime,
+		Trigger:    "heartbeat",
+	}
+}
+
+// finalizeAll runs the finalization of the engine loop once.
+func finalizeAll(m *Engine) {
+	for _, pending := range m.store.snapshot("", true) {
+		m.finalize(pending)
+	}
+}
+
+func TestEngineFinalizesOneRatePerRound(t *testing.T) {
+	m := newTestEngine(t, Config{})
+	var finalized []FinalizedRate
+	m.AddFinalizedHandler(func(rate FinalizedRate) { finalized = append(finalized, rate) })
+
+	// Every node signs its observation of the round with the same id
+	id := RoundID(testPair, 42)
+	signed := time.Now().Add(-time.Minute)
+	for i, price := range []float64{2000, 2002, 2001} {
+		needMore := m.Append(observation(byte(i+1), id, price, signed))
+		if want := i < 2; needMore != want {
+			t.Errorf("Append() of signature %d = %t, want %t", i+1, needMore, want)
+		}
+	}
+	// A duplicated signature does not count
+	if m.Append(observation(1, id, 2000, signed)) || m.GetSignedCount(testPair, id) != 3 {
+		t.Errorf("GetSignedCount() = %d after a duplicated signature, want 3", m.GetSignedCount(testPair, id))
+	}
+	finalizeAll(m)
+
+	if len(finalized) != 1 || finalized[0].MsgId != id || finalized[0].Price != 2001 || len(finalized[0].Messages) != 3 {
+		t.Fatalf("finalized = %+v, want one rate of %s at 2001 with 3 messages", finalized, id)
+	}
+	rate, err := m.Database().GetRate(id)
+	if err != nil {
+		t.Fatalf("GetRate() error: %v", err)
+	}
+	if rate.Price != "2001" || len(rate.Signatures) != 3 || !rate.LastSigned_Time.Equal(signed) || rate.Trigger != "heartbeat" {
+		t.Errorf("GetRate() = %+v, want price 2001 with 3 signatures last signed at %s", rate, signed)
+	}
+
+	// Late signatures of the stored round do not start a new one
+	if m.Append(observation(4, id, 2003, signed)) || len(m.Pending("")) != 0 {
+		t.Errorf("Pending() after a late signature = %+v, want none", m.Pending(""))
+	}
+	finalizeAll(m)
+	if len(finalized) != 1 {
+		t.Errorf("finalized %d rates of the round, want 1", len(finalized))
+	}
+}
+
+func TestEngineWaitsForLastSignature(t *testing.T) {
+	m := newTestEngine(t, Config{})
+	id := RoundID(testPair, 42)
+	for i := byte(1); i <= 3; i++ {
+		m.Append(observation(i, id, 2000, time.Now()))
+	}
+	finalizeAll(m)
+	if m.Database().ExistCheck(id) || len(m.Pending(testPair)) != 1 {
+		t.Error("the rate is stored before the finalization delay since the last signature")
+	}
+}
+
+func TestEngineImport(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	msgs := []protocol.ProtocolMessage{
+		observation(1, "ETH-USD-1", 2000, base.Add(2*time.Second)),
+		observation(2, "ETH-USD-1", 2004, base),
+		observation(3, "ETH-USD-1", 2002, base.Add(time.Second)),
+		// The outlier is rejected, the other node cannot choose the price
+		observation(4, "ETH-USD-1", 2600, base.Add(3*time.Second)),
+	}
+
+	tests := []struct {
+		name       string
+		msgs       []protocol.ProtocolMessage
+		lastSigned time.Time
+		err        bool
+	}{
+		{"valid", msgs, base.Add(2 * time.Second), false},
+		{"without quorum", msgs[:2], base.Add(2 * time.Second), true},
+		// A signer counts once
+		{"duplicated signer", []protocol.ProtocolMessage{msgs[0], msgs[1], msgs[0]}, base.Add(2 * time.Second), true},
+		// The last signed time is the time of the rejected outlier
+		{"last signed time of rejected", msgs, base.Add(3 * time.Second), true},
+		{"other last signed time", msgs, base, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestEngine(t, Config{Aggregation: Aggregation{Method: AggregateMedian, MaxSpread: 100}})
+			rate, err := m.Import("ETH-USD-1", testPair, "deviation", tt.lastSigned, tt.msgs)
+			if tt.err {
+				if err == nil || m.Database().ExistCheck("ETH-USD-1") {
+					t.Errorf("Import() error = %v, want an error and no rate", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error: %v", err)
+			}
+			stored, err := m.Database().GetRate("ETH-USD-1")
+			if err != nil {
+				t.Fatalf("GetRate() error: %v", err)
+			}
+			for _, r := range []*db.Rate{rate, stored} {
+				if r.Price != "2002" || len(r.Signatures) != 3 || r.Trigger != "deviation" || r.First_Signer != msgs[1].Signer.String() {
+					t.Errorf("Import() = %+v, want price 2002 with 3 signatures first signed by %s", r, msgs[1].Signer)
+				}
+			}
+			// Signatures of the imported rate are not collected again
+			m.Append(observation(5, "ETH-USD-1", 2002, base))
+			if m.GetSignedCount(testPair, "ETH-USD-1") != 0 {
+				t.Error("Append() of an imported rate started collecting it")
+			}
+			if _, err := m.Import("ETH-USD-1", testPair, "deviation", tt.lastSigned, tt.msgs); err == nil {
+				t.Error("Import() of a stored rate error = nil, want an error")
+			}
+		})
+	}
+}
+
+// signerSource is a registry source of the signers.
+type signerSource []registry.Signer
+
+func (s signerSource) Name() string { return "test" }
+
+func (s signerSource) Load(context.Context) ([]registry.Signer, error) { return s, nil }
+
+func TestEngineKeyRotation(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	rotation := now.Add(-time.Hour)
+	addr := func(n byte) common.Address { return observation(n, "", 0, now).Signer }
+	// Signer 1 rotated from key 1 to key 4 an hour ago, with an overlap of
+	// ten minutes
+	signers := registry.New(signerSource{
+		{Name: "node-1", Address: addr(1), ExpiresAt: rotation.Add(10 * time.Minute)},
+		{Name: "node-1", Address: addr(4), ActivatesAt: rotation},
+		{Name: "node-2", Address: addr(2)},
+		{Name: "node-3", Address: addr(3)},
+	})
+	if err := signers.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestEngine(t, Config{Signers: signers})
+
+	// Both keys of signer 1 count once within the overlap
+	overlap := rotation.Add(5 * time.Minute)
+	m.Append(observation(1, "ETH-USD-1", 2000, overlap))
+	m.Append(observation(4, "ETH-USD-1", 2000, overlap))
+	if !m.Append(observation(2, "ETH-USD-1", 2000, overlap)) {
+		t.Error("Append() counted both keys of a signer toward the quorum")
+	}
+
+	// The expired key is not counted now, but a rate signed with it while
+	// it was valid is still imported
+	m.Append(observation(1, "ETH-USD-2", 2000, now))
+	if m.GetSignedCount(testPair, "ETH-USD-2") != 0 {
+		t.Error("Append() of an expired key added the signature")
+	}
+	before := rotation.Add(-time.Minute)
+	msgs := []protocol.ProtocolMessage{
+		observation(1, "ETH-USD-0", 2000, before),
+		observation(2, "ETH-USD-0", 2000, before),
+		observation(3, "ETH-USD-0", 2000, before),
+	}
+	if _, err := m.Import("ETH-USD-0", testPair, "heartbeat", before, msgs); err != nil {
+		t.Errorf("Import() of a rate signed before the rotation error: %v", err)
+	}
+	// The new key was not valid before the rotation
+	msgs[0] = observation(4, "ETH-USD-00", 2000, before)
+	msgs[1].MsgId, msgs[2].MsgId = "ETH-USD-00", "ETH-USD-00"
+	if _, err := m.Import("ETH-USD-00", testPair, "heartbeat", before, msgs); err == nil {
+		t.Error("Import() of a rate signed with a key before its activation error = nil, want an error")
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"gossip-price/core/global"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// repositories returns the migrated repositories which run without an
+// external database service.
+func repositories(t *testing.T) map[string]RateRepository {
+	t.Helper()
+	sqlite, err := Open("sqlite://" + filepath.Join(t.TempDir(), "rate.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	repos := map[string]RateRepository{"memory": NewMemory(), "sqlite": sqlite}
+	for name, repo := range repos {
+		if err := repo.Migrate(context.Background()); err != nil {
+			t.Fatalf("%s: Migrate() error: %v", name, err)
+		}
+		t.Cleanup(repo.Close)
+	}
+	return repos
+}
+
+// testRate returns a rate of the pair last signed at the given time, with
+// a signature migrated without scheme and signed time.
+func testRate(id, pair string, lastSigned time.Time) *Rate {
+	return &Rate{
+		ID:              id,
+		Pair:            pair,
+		Price:           "2001.5",
+		First_Signer:    "0x0000000000000000000000000000000000000001",
+		Trigger:         "deviation",
+		LastSigned_Time: lastSigned,
+		Created_Time:    lastSigned.Add(time.Second),
+		Signatures: []SignatureData{
+			{Signer: "0x0000000000000000000000000000000000000003", Signature: "0x03", Price: "2003"},
+			{Signer: "0x0000000000000000000000000000000000000001", Signature: "0x01", Scheme: "eip712", Price: "2000.25", SignedTime: lastSigned.Add(-time.Second)},
+			{Signer: "0x0000000000000000000000000000000000000002", Signature: "0x02", Scheme: "ed25519", Price: "2001.5", SignedTime: lastSigned},
+		},
+	}
+}
+
+func TestRateRepository(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			rate := testRate("ETH/USD-1", "ETH/USD", base)
+			if _, err := repo.CreateRate(rate); err != nil {
+				t.Fatalf("CreateRate() error: %v", err)
+			}
+			if _, err := repo.CreateRate(rate); err == nil {
+				t.Error("CreateRate() of an existing rate error = nil, want an error")
+			}
+			if !repo.ExistCheck(rate.ID) || repo.ExistCheck("ETH/USD-2") {
+				t.Error("ExistCheck() does not match the stored rates")
+			}
+
+			got, err := repo.GetRate(rate.ID)
+			if err != nil {
+				t.Fatalf("GetRate() error: %v", err)
+			}
+			if !got.LastSigned_Time.Equal(rate.LastSigned_Time) || !got.Created_Time.Equal(rate.Created_Time) {
+				t.Errorf("GetRate() times = %s, %s, want %s, %s", got.LastSigned_Time, got.Created_Time, rate.LastSigned_Time, rate.Created_Time)
+			}
+			want := *rate
+			want.LastSigned_Time, want.Created_Time = got.LastSigned_Time, got.Created_Time
+			if !reflect.DeepEqual(got.Signatures, rate.Signatures) {
+				t.Errorf("GetRate() signatures = %+v, want %+v", got.Signatures, rate.Signatures)
+			}
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("GetRate() = %+v, want %+v", *got, want)
+			}
+			if _, err = repo.GetRate("ETH/USD-2"); !errors.Is(err, global.ErrRateNotFound) {
+				t.Errorf("GetRate() of an unknown rate error = %v, want %v", err, global.ErrRateNotFound)
+			}
+		})
+	}
+}
+
+func TestRateRepositoryRanges(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.LatestRate("ETH/USD"); !errors.Is(err, global.ErrRateNotFound) {
+				t.Errorf("LatestRate() of an empty repository error = %v, want %v", err, global.ErrRateNotFound)
+			}
+			// Rates are stored out of order, with a rate of another pair
+			// which is the latest one
+			for _, rate := range []*Rate{
+				testRate("ETH/USD-2", "ETH/USD", base.Add(2*time.Minute)),
+				testRate("ETH/USD-0", "ETH/USD", base),
+				testRate("BTC/USD-0", "BTC/USD", base.Add(time.Hour)),
+				testRate("ETH/USD-1", "ETH/USD", base.Add(time.Minute)),
+			} {
+				if _, err := repo.CreateRate(rate); err != nil {
+					t.Fatalf("CreateRate() error: %v", err)
+				}
+			}
+
+			latest, err := repo.LatestRate("ETH/USD")
+			if err != nil {
+				t.Fatalf("LatestRate() error: %v", err)
+			}
+			if latest.ID != "ETH/USD-2" || len(latest.Signatures) != 0 {
+				t.Errorf("LatestRate() = %s with %d signatures, want ETH/USD-2 without signatures", latest.ID, len(latest.Signatures))
+			}
+
+			tests := []struct {
+				from, to time.Time
+				limit    int
+				want     []string
+			}{
+				{base, base.Add(time.Hour), 10, []string{"ETH/USD-0", "ETH/USD-1", "ETH/USD-2"}},
+				// The range excludes its end
+				{base, base.Add(2 * time.Minute), 10, []string{"ETH/USD-0", "ETH/USD-1"}},
+				{base.Add(time.Second), base.Add(time.Hour), 10, []string{"ETH/USD-1", "ETH/USD-2"}},
+				{base, base.Add(time.Hour), 2, []string{"ETH/USD-0", "ETH/USD-1"}},
+				{base.Add(time.Hour), base.Add(2 * time.Hour), 10, nil},
+			}
+			for _, tt := range tests {
+				rates, err := repo.RatesBetween("ETH/USD", tt.from, tt.to, tt.limit)
+				if err != nil {
+					t.Fatalf("RatesBetween() error: %v", err)
+				}
+				var ids []string
+				for _, rate := range rates {
+					ids = append(ids, rate.ID)
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("RatesBetween(%s, %s, %d) = %v, want %v", tt.from, tt.to, tt.limit, ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, rawURL := range []string{"mysql://localhost/rate", "sqlite://", "://"} {
+		if repo, err := Open(rawURL); err == nil {
+			repo.Close()
+			t.Errorf("Open(%q) error = nil, want an error", rawURL)
+		}
+	}
+	repo, err := Open("memory://")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if _, ok := repo.(*Memory); !ok {
+		t.Errorf("Open(memory://) = %T, want *Memory", repo)
+	}
+}
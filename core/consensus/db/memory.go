@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"gossip-price/core/global"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is the in-memory implementation of the RateRepository. Rates are
+// lost when the process exits, so it is meant for tests and for nodes
+// which do not need the history.
+type Memory struct {
+	mu    sync.RWMutex
+	rates map[string]Rate
+}
+
+// NewMemory returns an empty in-memory repository
+func NewMemory() *Memory {
+	return &Memory{rates: make(map[string]Rate)}
+}
+
+// CreateRate implements the RateRepository interface
+func (d *Memory) CreateRate(rate *Rate) (*Rate, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.rates[rate.ID]; ok {
+		return nil, fmt.Errorf("database error, rate %s already exists", rate.ID)
+	}
+	stored := *rate
+	stored.Signatures = append([]SignatureData(nil), rate.Signatures...)
+	d.rates[rate.ID] = stored
+	return rate, nil
+}
+
+// GetRate implements the RateRepository interface
+func (d *Memory) GetRate(id string) (*Rate, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rate, ok := d.rates[id]
+	if !ok {
+		return nil, global.ErrRateNotFound
+	}
+	rate.Signatures = append([]SignatureData(nil), rate.Signatures...)
+	return &rate, nil
+}
+
+// ExistCheck implements the RateRepository interface
+func (d *Memory) ExistCheck(id string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, ok := d.rates[id]
+	return ok
+}
+
+// LatestRate implements the RateRepository interface
+func (d *Memory) LatestRate(pair string) (*Rate, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var latest *Rate
+	for _, rate := range d.rates {
+		if rate.Pair != pair {
+			continue
+		}
+		if latest == nil || rate.LastSigned_Time.After(latest.LastSigned_Time) {
+			r := rate
+			latest = &r
+		}
+	}
+	if latest == nil {
+		return nil, global.ErrRateNotFound
+	}
+	latest.Signatures = nil
+	return latest, nil
+}
+
+// RatesBetween implements the RateRepository interface
+func (d *Memory) RatesBetween(pair string, from, to time.Time, limit int) ([]Rate, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rates := make([]Rate, 0)
+	for _, rate := range d.rates {
+		if rate.Pair != pair || rate.LastSigned_Time.Before(from) || !rate.LastSigned_Time.Before(to) {
+			continue
+		}
+		rate.Signatures = nil
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].LastSigned_Time.Before(rates[j].LastSigned_Time)
+	})
+	if len(rates) > limit {
+		rates = rates[:limit]
+	}
+	return rates, nil
+}
+
+// Migrate implements the RateRepository interface, there is nothing to
+// migrate in memory
+func (d *Memory) Migrate(ctx context.Context) error {
+	return nil
+}
+
+// Close implements the RateRepository interface
+func (d *Memory) Close() {}
@@ -2,12 +2,27 @@ package db
 
 import "time"
 
-// Rate schema of the rate table
+// Rate schema of the rate table, with the signatures stored in the
+// rate_signature table
 type Rate struct {
 	ID              string
+	Pair            string
 	Price           string
 	First_Signer    string
-	Sign_Data       string
+	Trigger         string
 	LastSigned_Time time.Time
 	Created_Time    time.Time
+	Signatures      []SignatureData
+}
+
+// SignatureData is a single signature of a stored rate with the price
+// observed by the signer. The signed time is zero for signatures migrated
+// from rates without signed times, and the scheme is empty for signatures
+// stored before the scheme was.
+type SignatureData struct {
+	Signer     string    `json:"signer"`
+	Signature  string    `json:"signature"`
+	Scheme     string    `json:"scheme,omitempty"`
+	Price      string    `json:"price"`
+	SignedTime time.Time `json:"signed_time"`
 }
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gossip-price/core/global"
+	"gossip-price/migrations"
+	"log"
+	_ "modernc.org/sqlite"
+	"time"
+)
+
+// SQLite is the embedded SQLite implementation of the RateRepository. It
+// uses a pure Go driver, so it needs no external database service.
+type SQLite struct {
+	Conn *sql.DB
+}
+
+// NewSQLite opens the SQLite database file of the given path, creating it
+// if it does not exist
+func NewSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errors.New("database error, sqlite path is empty")
+	}
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so a single connection avoids lock
+	// errors between the engine and the API
+	conn.SetMaxOpenConns(1)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error connecting to the database: %w", err)
+	}
+	return &SQLite{Conn: conn}, nil
+}
+
+// CreateRate implements the RateRepository interface
+func (d *SQLite) CreateRate(rate *Rate) (*Rate, error) {
+	ctx := context.Background()
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO rate (id, pair, price, first_signer, trigger, lastsigned_time, created_time)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, rate.ID, rate.Pair, rate.Price, rate.First_Signer, rate.Trigger,
+		rate.LastSigned_Time.UnixNano(), rate.Created_Time.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range rate.Signatures {
+		var scheme, price, signedAt any
+		if s.Scheme != "" {
+			scheme = s.Scheme
+		}
+		if s.Price != "" {
+			price = s.Price
+		}
+		if !s.SignedTime.IsZero() {
+			signedAt = s.SignedTime.UnixNano()
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_signature (rate_id, signer, signature, scheme, price, signed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, rate.ID, s.Signer, s.Signature, scheme, price, signedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Printf("Message(%s) of %s is added to database", rate.ID, rate.Pair)
+	return rate, nil
+}
+
+// GetRate implements the RateRepository interface
+func (d *SQLite) GetRate(id string) (*Rate, error) {
+	row := d.Conn.QueryRow(`SELECT `+sqliteRateColumns+` FROM rate WHERE id = ?`, id)
+	rate, err := scanSQLiteRate(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.Conn.Query(`SELECT signer, signature, scheme, price, signed_at FROM rate_signature
+	WHERE rate_id = ? ORDER BY signed_at ASC, signer ASC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rate.Signatures = make([]SignatureData, 0)
+	for rows.Next() {
+		var (
+			s        SignatureData
+			scheme   sql.NullString
+			price    sql.NullString
+			signedAt sql.NullInt64
+		)
+		if err := rows.Scan(&s.Signer, &s.Signature, &scheme, &price, &signedAt); err != nil {
+			return nil, err
+		}
+		s.Scheme = scheme.String
+		s.Price = price.String
+		if signedAt.Valid {
+			s.SignedTime = time.Unix(0, signedAt.Int64)
+		}
+		rate.Signatures = append(rate.Signatures, s)
+	}
+	return rate, rows.Err()
+}
+
+// ExistCheck implements the RateRepository interface
+func (d *SQLite) ExistCheck(id string) bool {
+	var exists bool
+	err := d.Conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM rate WHERE id = ?)`, id).Scan(&exists)
+	return err == nil && exists
+}
+
+// LatestRate implements the RateRepository interface
+func (d *SQLite) LatestRate(pair string) (*Rate, error) {
+	row := d.Conn.QueryRow(`SELECT `+sqliteRateColumns+` FROM rate WHERE pair = ?
+	ORDER BY lastsigned_time DESC LIMIT 1`, pair)
+	return scanSQLiteRate(row)
+}
+
+// RatesBetween implements the RateRepository interface
+func (d *SQLite) RatesBetween(pair string, from, to time.Time, limit int) ([]Rate, error) {
+	rows, err := d.Conn.Query(`SELECT `+sqliteRateColumns+` FROM rate
+	WHERE pair = ? AND lastsigned_time >= ? AND lastsigned_time < ?
+	ORDER BY lastsigned_time ASC LIMIT ?`, pair, from.UnixNano(), to.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make([]Rate, 0)
+	for rows.Next() {
+		rate, err := scanSQLiteRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, rows.Err()
+}
+
+// Migrate implements the RateRepository interface
+func (d *SQLite) Migrate(ctx context.Context) error {
+	list, err := LoadMigrations(migrations.SQLite, "sqlite")
+	if err != nil {
+		return err
+	}
+	_, err = d.Conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT    NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("migration error, unable to create schema_migrations: %w", err)
+	}
+
+	var current int
+	err = d.Conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if m.Version <= current {
+			continue
+		}
+		tx, err := d.Conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, m.SQL); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration error, %s failed: %w", m.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().UnixNano())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Migration %s is applied", m.Name)
+	}
+	return nil
+}
+
+// Close implements the RateRepository interface
+func (d *SQLite) Close() {
+	_ = d.Conn.Close()
+}
+
+// sqliteRateColumns is the list of the columns of the rate table, in the
+// order expected by scanSQLiteRate
+const sqliteRateColumns = `id, pair, price, first_signer, trigger, lastsigned_time, created_time`
+
+// scanSQLiteRate scans a row selected with sqliteRateColumns
+func scanSQLiteRate(row interface{ Scan(dest ...any) error }) (*Rate, error) {
+	var (
+		rate                 Rate
+		lastSigned, creation int64
+	)
+	err := row.Scan(&rate.ID, &rate.Pair, &rate.Price, &rate.First_Signer, &rate.Trigger, &lastSigned, &creation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, global.ErrRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rate.LastSigned_Time = time.Unix(0, lastSigned)
+	rate.Created_Time = time.Unix(0, creation)
+	return &rate, nil
+}
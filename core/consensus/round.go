@@ -0,0 +1,51 @@
+package consensus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rounds slices the time into rounds of equal length counted from a shared
+// epoch, so every node derives the same round number for the same time.
+type Rounds struct {
+	// Epoch is the start of the first round.
+	Epoch time.Time
+	// Interval is the length of a round.
+	Interval time.Duration
+}
+
+// At returns the number of the round which contains t. Times before the
+// epoch belong to the first round.
+func (r Rounds) At(t time.Time) uint64 {
+	if !t.After(r.Epoch) || r.Interval <= 0 {
+		return 0
+	}
+	return uint64(t.Sub(r.Epoch) / r.Interval)
+}
+
+// Start returns the start time of the round.
+func (r Rounds) Start(round uint64) time.Time {
+	return r.Epoch.Add(time.Duration(round) * r.Interval)
+}
+
+// RoundID returns the message id of the round of the pair, e.g.
+// "ETH-USD-28930211". All nodes sign their observation of the round with
+// the same id, so the round collects a single quorum.
+func RoundID(pair string, round uint64) string {
+	return strings.ReplaceAll(pair, "/", "-") + "-" + strconv.FormatUint(round, 10)
+}
+
+// ParseRoundID returns the pair and the round number of the message id.
+func ParseRoundID(id string) (string, uint64, error) {
+	i := strings.LastIndex(id, "-")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("consensus error, invalid round id: %q", id)
+	}
+	round, err := strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("consensus error, invalid round id: %q", id)
+	}
+	return strings.ReplaceAll(id[:i], "-", "/"), round, nil
+}
@@ -0,0 +1,63 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundsAt(t *testing.T) {
+	epoch := time.Unix(1700000000, 0)
+	r := Rounds{Epoch: epoch, Interval: time.Minute}
+
+	tests := []struct {
+		t    time.Time
+		want uint64
+	}{
+		{epoch.Add(-time.Hour), 0},
+		{epoch, 0},
+		{epoch.Add(59 * time.Second), 0},
+		// Rounds start at their first second
+		{epoch.Add(time.Minute), 1},
+		{epoch.Add(time.Hour + 30*time.Second), 60},
+	}
+	for _, tt := range tests {
+		if got := r.At(tt.t); got != tt.want {
+			t.Errorf("At(%s) = %d, want %d", tt.t, got, tt.want)
+		}
+	}
+	for round := uint64(0); round < 3; round++ {
+		if got := r.At(r.Start(round)); got != round {
+			t.Errorf("At(Start(%d)) = %d, want %d", round, got, round)
+		}
+	}
+	if got := (Rounds{Epoch: epoch}).At(epoch.Add(time.Hour)); got != 0 {
+		t.Errorf("At() without interval = %d, want 0", got)
+	}
+}
+
+func TestRoundID(t *testing.T) {
+	tests := []struct {
+		pair  string
+		round uint64
+		want  string
+	}{
+		{"ETH/USD", 28930211, "ETH-USD-28930211"},
+		{"BTC/USD", 0, "BTC-USD-0"},
+		{"ETH", 1, "ETH-1"},
+	}
+	for _, tt := range tests {
+		id := RoundID(tt.pair, tt.round)
+		if id != tt.want {
+			t.Errorf("RoundID(%s, %d) = %s, want %s", tt.pair, tt.round, id, tt.want)
+		}
+		pair, round, err := ParseRoundID(id)
+		if err != nil || pair != tt.pair || round != tt.round {
+			t.Errorf("ParseRoundID(%s) = %s, %d, %v, want %s, %d", id, pair, round, err, tt.pair, tt.round)
+		}
+	}
+	for _, id := range []string{"", "ETH/USD", "-1", "ETH-USD-", "ETH-USD-x"} {
+		if _, _, err := ParseRoundID(id); err == nil {
+			t.Errorf("ParseRoundID(%q) error = nil, want an error", id)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package consensus
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	protocol "gossip-price/core/gossip"
+	"log"
+	"sync"
+	"time"
+)
+
+// key identifies a message of a pair.
+type key struct {
+	pair  string
+	msgId string
+}
+
+// entry is a message which is collecting signatures.
+type entry struct {
+	firstSigner common.Address
+	startedAt   time.Time
+	messages    []protocol.ProtocolMessage
+	// verified is set when the message reached the quorum.
+	verified bool
+}
+
+// store keeps the messages which are collecting signatures. Messages are
+// removed when they are stored, when they are older than the ttl, or when
+// the store is full. Stored messages are remembered for the ttl, so late
+// signatures do not start collecting again. All methods are safe for
+// concurrent use.
+type store struct {
+	mu        sync.Mutex
+	pairs     map[string]bool
+	entries   map[key]*entry
+	finalized map[key]time.Time
+	ttl       time.Duration
+	max       int
+}
+
+func newStore(pairs []string, ttl time.Duration, max int) *store {
+	s := &store{
+		pairs:     make(map[string]bool, len(pairs)),
+		entries:   make(map[key]*entry),
+		finalized: make(map[key]time.Time),
+		ttl:       ttl,
+		max:       max,
+	}
+	for _, pair := range pairs {
+		s.pairs[pair] = true
+	}
+	return s
+}
+
+// add appends the message and returns a copy of all messages of its
+// message id, and the time the first one was added. The message is not
+// added if its pair is not configured, if it's already stored, or if its
+// signer already signed it.
+func (s *store) add(msg protocol.ProtocolMessage, now time.Time) ([]protocol.ProtocolMessage, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{pair: msg.Pair, msgId: msg.MsgId}
+	if !s.pairs[k.pair] {
+		return nil, time.Time{}, false
+	}
+	if _, ok := s.finalized[k]; ok {
+		return nil, time.Time{}, false
+	}
+	e, ok := s.entries[k]
+	if !ok {
+		if s.max > 0 && len(s.entries) >= s.max && !s.evictOldest() {
+			log.Printf("Discarded message(%s) of %s, too many pending messages", k.msgId, k.pair)
+			return nil, time.Time{}, false
+		}
+		// When it's first signer, allocate entry and set signer as first
+		e = &entry{firstSigner: msg.Signer, startedAt: now}
+		s.entries[k] = e
+	}
+	for _, m := range e.messages {
+		if m.Signer == msg.Signer {
+			return nil, time.Time{}, false
+		}
+	}
+	e.messages = append(e.messages, msg)
+	return append([]protocol.ProtocolMessage(nil), e.messages...), e.startedAt, true
+}
+
+// evictOldest removes the oldest message which did not reach the quorum
+// yet. It returns false if all messages reached the quorum.
+func (s *store) evictOldest() bool {
+	var (
+		oldest key
+		found  bool
+		at     time.Time
+	)
+	for k, e := range s.entries {
+		if e.verified {
+			continue
+		}
+		if !found || e.startedAt.Before(at) {
+			oldest, at, found = k, e.startedAt, true
+		}
+	}
+	if found {
+		delete(s.entries, oldest)
+	}
+	return found
+}
+
+// markVerified marks the message as reached the quorum.
+func (s *store) markVerified(pair, msgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key{pair: pair, msgId: msgId}]; ok {
+		e.verified = true
+	}
+}
+
+// hasSigned returns true if the signer signed the message.
+func (s *store) hasSigned(pair, msgId string, signer common.Address) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key{pair: pair, msgId: msgId}]
+	if !ok {
+		return false
+	}
+	for _, m := range e.messages {
+		if m.Signer == signer {
+			return true
+		}
+	}
+	return false
+}
+
+// count returns the number of signatures of the message.
+func (s *store) count(pair, msgId string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key{pair: pair, msgId: msgId}]; ok {
+		return len(e.messages)
+	}
+	return 0
+}
+
+// size returns the number of messages of the pair.
+func (s *store) size(pair string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for k := range s.entries {
+		if k.pair == pair {
+			n++
+		}
+	}
+	return n
+}
+
+// snapshot returns a copy of the messages of the pair, or of all pairs if
+// pair is empty. If verified is true, only the messages which reached the
+// quorum are returned.
+func (s *store) snapshot(pair string, verified bool) []PendingMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]PendingMessage, 0)
+	for k, e := range s.entries {
+		if (pair != "" && k.pair != pair) || (verified && !e.verified) {
+			continue
+		}
+		res = append(res, PendingMessage{
+			MsgId:       k.msgId,
+			Pair:        k.pair,
+			FirstSigner: e.firstSigner,
+			StartedAt:   e.startedAt,
+			Messages:    append([]protocol.ProtocolMessage(nil), e.messages...),
+		})
+	}
+	return res
+}
+
+// finalize removes the stored message, and remembers it for the ttl.
+func (s *store) finalize(pair, msgId string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{pair: pair, msgId: msgId}
+	delete(s.entries, k)
+	s.finalized[k] = now
+}
+
+// remove removes the message without remembering it.
+func (s *store) remove(pair, msgId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key{pair: pair, msgId: msgId})
+}
+
+// evict removes the messages and the stored message ids which are older
+// than the ttl, and returns the removed messages.
+func (s *store) evict(now time.Time) []PendingMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var evicted []PendingMessage
+	for k, e := range s.entries {
+		if now.Sub(e.startedAt) < s.ttl {
+			continue
+		}
+		evicted = append(evicted, PendingMessage{
+			MsgId:       k.msgId,
+			Pair:        k.pair,
+			FirstSigner: e.firstSigner,
+			StartedAt:   e.startedAt,
+			Messages:    e.messages,
+		})
+		delete(s.entries, k)
+	}
+	for k, at := range s.finalized {
+		if now.Sub(at) >= s.ttl {
+			delete(s.finalized, k)
+		}
+	}
+	return evicted
+}
@@ -0,0 +1,144 @@
+package consensus
+
+import (
+	"gossip-price/core/consensus/db"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewEnginePendingTTL(t *testing.T) {
+	tests := []struct {
+		ttl time.Duration
+		err bool
+	}{
+		{-time.Minute, true},
+		{0, true},
+		// A verified message is stored up to a minute after its last
+		// signature
+		{59 * time.Second, true},
+		{time.Minute, false},
+		{10 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		_, err := NewEngine(Config{
+			Pairs:       []string{testPair},
+			Database:    db.NewMemory(),
+			Aggregation: Aggregation{Method: AggregateMedian},
+			PendingTTL:  tt.ttl,
+		})
+		if (err != nil) != tt.err {
+			t.Errorf("NewEngine() with ttl %s error = %v, want error %t", tt.ttl, err, tt.err)
+		}
+	}
+}
+
+func TestStoreEvict(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newStore([]string{testPair}, time.Minute, 0)
+
+	s.add(observation(1, "ETH-USD-1", 2000, now), now)
+	s.add(observation(1, "ETH-USD-2", 2000, now), now.Add(30*time.Second))
+	// Signatures added later do not extend the ttl of the message
+	s.add(observation(2, "ETH-USD-1", 2000, now), now.Add(50*time.Second))
+	s.add(observation(1, "ETH-USD-3", 2000, now), now)
+	s.finalize(testPair, "ETH-USD-3", now)
+
+	evicted := s.evict(now.Add(time.Minute))
+	if len(evicted) != 1 || evicted[0].MsgId != "ETH-USD-1" || len(evicted[0].Messages) != 2 {
+		t.Fatalf("evict() = %+v, want ETH-USD-1 with 2 messages", evicted)
+	}
+	if got := s.snapshot("", false); len(got) != 1 || got[0].MsgId != "ETH-USD-2" {
+		t.Errorf("snapshot() after evict() = %+v, want ETH-USD-2", got)
+	}
+	// The stored message id is forgotten with the ttl, so its late
+	// signatures are not rejected forever
+	if _, _, ok := s.add(observation(2, "ETH-USD-3", 2000, now), now.Add(time.Minute)); !ok {
+		t.Error("add() of a message stored before the ttl = false, want true")
+	}
+}
+
+func TestStoreFinalize(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newStore([]string{testPair}, time.Minute, 0)
+
+	s.add(observation(1, "ETH-USD-1", 2000, now), now)
+	s.finalize(testPair, "ETH-USD-1", now)
+	if s.size(testPair) != 0 {
+		t.Errorf("size() after finalize() = %d, want 0", s.size(testPair))
+	}
+	if _, _, ok := s.add(observation(2, "ETH-USD-1", 2000, now), now.Add(time.Second)); ok {
+		t.Error("add() of a stored message = true, want false")
+	}
+
+	// A removed message is not remembered
+	s.add(observation(1, "ETH-USD-2", 2000, now), now)
+	s.remove(testPair, "ETH-USD-2")
+	if _, _, ok := s.add(observation(2, "ETH-USD-2", 2000, now), now.Add(time.Second)); !ok {
+		t.Error("add() of a removed message = false, want true")
+	}
+
+	other := observation(1, "BTC-USD-1", 2000, now)
+	other.Pair = "BTC/USD"
+	if _, _, ok := s.add(other, now); ok {
+		t.Error("add() of a not configured pair = true, want false")
+	}
+}
+
+func TestStoreMaxPending(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newStore([]string{testPair}, time.Minute, 2)
+
+	s.add(observation(1, "ETH-USD-1", 2000, now), now.Add(time.Second))
+	s.add(observation(1, "ETH-USD-2", 2000, now), now)
+	// The oldest message which did not reach the quorum is dropped
+	if _, _, ok := s.add(observation(1, "ETH-USD-3", 2000, now), now.Add(2*time.Second)); !ok {
+		t.Fatal("add() to a full store = false, want true")
+	}
+	if s.count(testPair, "ETH-USD-2") != 0 || s.count(testPair, "ETH-USD-1") != 1 || s.size(testPair) != 2 {
+		t.Errorf("add() to a full store kept %+v, want ETH-USD-1 and ETH-USD-3", s.snapshot("", false))
+	}
+	// New signatures of pending messages are still added
+	if _, _, ok := s.add(observation(2, "ETH-USD-1", 2000, now), now); !ok {
+		t.Error("add() of a pending message to a full store = false, want true")
+	}
+
+	// Messages which reached the quorum are not dropped
+	s.markVerified(testPair, "ETH-USD-1")
+	s.markVerified(testPair, "ETH-USD-3")
+	if _, _, ok := s.add(observation(1, "ETH-USD-4", 2000, now), now); ok {
+		t.Error("add() to a store full of verified messages = true, want false")
+	}
+	if got := s.snapshot(testPair, true); len(got) != 2 {
+		t.Errorf("snapshot() of verified messages = %d, want 2", len(got))
+	}
+}
+
+func TestEngineConcurrentAppend(t *testing.T) {
+	m := newTestEngine(t, Config{})
+	signed := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(n byte) {
+			defer wg.Done()
+			for round := uint64(0); round < 10; round++ {
+				id := RoundID(testPair, round)
+				m.Append(observation(n, id, 2000, signed))
+				m.CheckAlreadySigned(testPair, id, observation(n, id, 0, signed).Signer)
+				m.Pending(testPair)
+			}
+		}(byte(i))
+	}
+	wg.Wait()
+
+	for round := uint64(0); round < 10; round++ {
+		if got := m.GetSignedCount(testPair, RoundID(testPair, round)); got != 20 {
+			t.Errorf("GetSignedCount() of round %d = %d, want 20", round, got)
+		}
+	}
+	if got := len(m.store.snapshot("", true)); got != 10 {
+		t.Errorf("verified messages = %d, want 10", got)
+	}
+}
@@ -0,0 +1,161 @@
+package consensus
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	protocol "gossip-price/core/gossip"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WAL operations
+const (
+	// walAdd records a signature added to a message.
+	walAdd = "add"
+	// walFinalize records a message which is stored to the database.
+	walFinalize = "finalize"
+	// walDrop records a message which is dropped without being stored.
+	walDrop = "drop"
+)
+
+// walRecord is a single line of the WAL.
+type walRecord struct {
+	Op      string                    `json:"op"`
+	Time    time.Time                 `json:"time"`
+	Pair    string                    `json:"pair"`
+	MsgId   string                    `json:"id"`
+	Message *protocol.ProtocolMessage `json:"message,omitempty"`
+}
+
+// WAL is an append-only log of the signatures collected by the engine, and
+// of the messages which are stored or dropped. It's replayed on startup, so
+// the pending messages survive a restart. Every record is a JSON line, and
+// it's synced to the disk before the write returns. The log is compacted
+// to the pending messages by the engine loop.
+type WAL struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenWAL opens the WAL of the path, and creates it if it does not exist.
+func OpenWAL(path string) (*WAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("WAL error, unable to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("WAL error, unable to open %s: %w", path, err)
+	}
+	return &WAL{path: path, file: file}, nil
+}
+
+// Close closes the WAL.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}
+
+// append writes the record and syncs it to the disk.
+func (w *WAL) append(rec walRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// records reads all records of the WAL. A truncated last line, written
+// while the node crashed, is skipped.
+func (w *WAL) records() ([]walRecord, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := os.Open(w.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []walRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Printf("WAL %s: skipping invalid record at line %d: %v", w.path, line, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// compact replaces the WAL with the add records of the pending messages
+// returned by snapshot. The snapshot is taken while the WAL is locked, so
+// records appended concurrently are either in the snapshot or appended to
+// the new log. The new log is written to a temporary file, which is renamed
+// over the old one, so the WAL is never partially written.
+func (w *WAL) compact(snapshot func() []PendingMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := snapshot()
+	tmp := w.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	enc := json.NewEncoder(writer)
+	for _, p := range pending {
+		for i := range p.Messages {
+			err = enc.Encode(walRecord{
+				Op:      walAdd,
+				Time:    p.StartedAt,
+				Pair:    p.Pair,
+				MsgId:   p.MsgId,
+				Message: &p.Messages[i],
+			})
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return err
+	}
+	// The old file is kept open until the new one is opened, so a failed
+	// open leaves the WAL writable
+	file, err = os.OpenFile(w.path, os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	return nil
+}
@@ -0,0 +1,135 @@
+package consensus
+
+import (
+	"gossip-price/core/consensus/db"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestWAL opens the WAL of the path, which is closed at the end of
+// the test.
+func openTestWAL(t *testing.T, path string) *WAL {
+	t.Helper()
+	wal, err := OpenWAL(path)
+	if err != nil {
+		t.Fatalf("OpenWAL() error: %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	return wal
+}
+
+// walLines returns the number of records of the WAL file.
+func walLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal", "pending.log")
+	database := db.NewMemory()
+	signed := time.Now().Add(-time.Minute)
+
+	m := newTestEngine(t, Config{Database: database, WAL: openTestWAL(t, path)})
+	// A stored message, a message which reached the quorum, a message
+	// collecting signatures and a dropped one
+	for i := byte(1); i <= 3; i++ {
+		m.Append(observation(i, "ETH-USD-3", 2000, signed))
+	}
+	finalizeAll(m)
+	for i := byte(1); i <= 3; i++ {
+		m.Append(observation(i, "ETH-USD-2", 2000, signed))
+	}
+	m.Append(observation(1, "ETH-USD-1", 2000, signed))
+	m.Append(observation(1, "ETH-USD-4", 2000, signed))
+	m.store.remove(testPair, "ETH-USD-4")
+	m.writeWAL(walRecord{Op: walDrop, Time: time.Now(), Pair: testPair, MsgId: "ETH-USD-4"})
+	if got := walLines(t, path); got != 10 {
+		t.Fatalf("WAL has %d records, want 10", got)
+	}
+
+	// The node restarts with the same WAL
+	m = newTestEngine(t, Config{Database: database, WAL: openTestWAL(t, path)})
+	pending := map[string]int{}
+	for _, p := range m.Pending(testPair) {
+		pending[p.MsgId] = len(p.Messages)
+	}
+	if len(pending) != 2 || pending["ETH-USD-1"] != 1 || pending["ETH-USD-2"] != 3 {
+		t.Fatalf("Pending() after replay = %v, want ETH-USD-1 with 1 message and ETH-USD-2 with 3", pending)
+	}
+	// The message which reached the quorum is stored by the next loop, and
+	// late signatures of the stored message are still ignored
+	finalizeAll(m)
+	if !database.ExistCheck("ETH-USD-2") {
+		t.Error("the replayed message which reached the quorum is not stored")
+	}
+	m.Append(observation(4, "ETH-USD-3", 2000, signed))
+	if m.GetSignedCount(testPair, "ETH-USD-3") != 0 {
+		t.Error("Append() of a stored message after replay started collecting it again")
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.log")
+	signed := time.Now()
+
+	m := newTestEngine(t, Config{WAL: openTestWAL(t, path)})
+	for i := byte(1); i <= 2; i++ {
+		m.Append(observation(i, "ETH-USD-1", 2000, signed))
+		m.Append(observation(i, "ETH-USD-2", 2000, signed))
+	}
+	m.store.remove(testPair, "ETH-USD-2")
+	m.writeWAL(walRecord{Op: walDrop, Time: time.Now(), Pair: testPair, MsgId: "ETH-USD-2"})
+
+	// The log is compacted to the add records of the pending messages
+	if err := m.wal.compact(func() []PendingMessage { return m.store.snapshot("", false) }); err != nil {
+		t.Fatalf("compact() error: %v", err)
+	}
+	if got := walLines(t, path); got != 2 {
+		t.Errorf("WAL has %d records after compact(), want 2", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file of compact() is left: %v", err)
+	}
+
+	// The compacted WAL is still appended to, and replayed
+	m.Append(observation(3, "ETH-USD-1", 2000, signed))
+	if got := walLines(t, path); got != 3 {
+		t.Errorf("WAL has %d records after Append(), want 3", got)
+	}
+	m = newTestEngine(t, Config{WAL: openTestWAL(t, path)})
+	if got := m.Pending(testPair); len(got) != 1 || len(got[0].Messages) != 3 {
+		t.Errorf("Pending() after replay = %+v, want ETH-USD-1 with 3 messages", got)
+	}
+}
+
+func TestWALSkipsTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.log")
+	m := newTestEngine(t, Config{WAL: openTestWAL(t, path)})
+	m.Append(observation(1, "ETH-USD-1", 2000, time.Now()))
+
+	// The node crashed while writing the next record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"op":"add","time":`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	m = newTestEngine(t, Config{WAL: openTestWAL(t, path)})
+	if got := m.Pending(testPair); len(got) != 1 || len(got[0].Messages) != 1 {
+		t.Errorf("Pending() after replay = %+v, want ETH-USD-1 with 1 message", got)
+	}
+	// The replay compacts the WAL, the truncated record is removed
+	if got := walLines(t, path); got != 1 {
+		t.Errorf("WAL has %d records after replay, want 1", got)
+	}
+}
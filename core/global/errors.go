@@ -17,4 +17,26 @@ var (
 
 	// ErrPubSubDisabled is returned when the protocol is disabled
 	ErrPubSubDisabled = errors.New("pubsub protocol is disabled")
+
+	// ErrSignerMismatch is returned when the signer of a message does not
+	// belong to the key used to verify it
+	ErrSignerMismatch = errors.New("signer does not match the public key")
+
+	// ErrInvalidSignature is returned when the signature of a message is not valid
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrUnsupportedVersion is returned when the signing version of a message is not supported
+	ErrUnsupportedVersion = errors.New("unsupported signing version")
+
+	// ErrInvalidMessage is returned when a message cannot be encoded for signing
+	ErrInvalidMessage = errors.New("invalid message")
+
+	// ErrRateNotFound is returned when the requested rate is not stored
+	ErrRateNotFound = errors.New("rate not found")
+
+	// ErrUnknownSigner is returned when the signer is not in the signer registry
+	ErrUnknownSigner = errors.New("unknown signer")
+
+	// ErrSigningDenied is returned when the signing policy denies to sign a message
+	ErrSigningDenied = errors.New("signing denied by policy")
 )
@@ -2,9 +2,37 @@ package global
 
 import (
 	common2 "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// PeerIDToAddress returns the signer address of the peer. For secp256k1
+// keys it is the Ethereum address of the key, otherwise the peer ID bytes
+// are truncated to an address.
 func PeerIDToAddress(id peer.ID) common2.Address {
+	pub, err := id.ExtractPublicKey()
+	if err == nil {
+		if addr, ok := PublicKeyToAddress(pub); ok {
+			return addr
+		}
+	}
 	return common2.BytesToAddress([]byte(id))
 }
+
+// PublicKeyToAddress returns the Ethereum address of a secp256k1 public
+// key. The second value is false for other key types.
+func PublicKeyToAddress(pub crypto.PubKey) (common2.Address, bool) {
+	if pub.Type() != crypto.Secp256k1 {
+		return common2.Address{}, false
+	}
+	raw, err := pub.Raw()
+	if err != nil {
+		return common2.Address{}, false
+	}
+	ecdsaPub, err := ethcrypto.DecompressPubkey(raw)
+	if err != nil {
+		return common2.Address{}, false
+	}
+	return ethcrypto.PubkeyToAddress(*ecdsaPub), true
+}
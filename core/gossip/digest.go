@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	"math"
+)
+
+// SigningVersion is the version of the signing payload layout produced by
+// this package.
+const SigningVersion uint8 = 1
+
+// Signing schemes of the messages.
+const (
+	// SchemeEd25519 messages are signed with the libp2p node key over the
+	// Digest of the message.
+	SchemeEd25519 = "ed25519"
+	// SchemeEIP712 messages are signed with a secp256k1 key over the
+	// EIP-712 TypedDataHash of the message, so the signer can be recovered
+	// with ecrecover.
+	SchemeEIP712 = "eip712"
+)
+
+// SigningPayload returns the canonical byte representation of the message
+// that is hashed and signed. Version 1 of the layout is, with all integers
+// encoded in big-endian order:
+//
+//	offset     size  field
+//	0          1     version, always 0x01
+//	1          2     length of the pair (n)
+//	3          n     pair, UTF-8, e.g. "ETH/USD"
+//	3+n        2     length of the message ID (m)
+//	5+n        m     message ID, UTF-8
+//	5+n+m      8     price, IEEE 754 binary64
+//	13+n+m     8     signed time, unix seconds, signed
+//	21+n+m     20    signer address
+func SigningPayload(msg *ProtocolMessage) ([]byte, error) {
+	if msg.Version != SigningVersion {
+		return nil, fmt.Errorf("%w: %d", global.ErrUnsupportedVersion, msg.Version)
+	}
+	if len(msg.Pair) > math.MaxUint16 || len(msg.MsgId) > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: field too long", global.ErrInvalidMessage)
+	}
+	buf := make([]byte, 0, 41+len(msg.Pair)+len(msg.MsgId))
+	buf = append(buf, msg.Version)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.Pair)))
+	buf = append(buf, msg.Pair...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.MsgId)))
+	buf = append(buf, msg.MsgId...)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(msg.Price))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(msg.SignedTime.Unix()))
+	buf = append(buf, msg.Signer.Bytes()...)
+	return buf, nil
+}
+
+// Digest returns the keccak256 hash of the signing payload. This is the
+// value signed by the signer key.
+func Digest(msg *ProtocolMessage) ([]byte, error) {
+	payload, err := SigningPayload(msg)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(payload), nil
+}
+
+// Verify checks if the message is signed by its signer. EIP-712 messages
+// are verified by recovering the signer address from the signature, in
+// which case pub may be nil. Other messages must be signed by the given
+// public key, and the signer address must belong to that key.
+func Verify(msg *ProtocolMessage, pub p2pcrypto.PubKey) error {
+	switch msg.Scheme {
+	case SchemeEIP712:
+		signer, err := RecoverSigner(msg, DefaultDomain)
+		if err != nil {
+			return err
+		}
+		if signer != msg.Signer {
+			return global.ErrSignerMismatch
+		}
+		return nil
+	case SchemeEd25519:
+	default:
+		return fmt.Errorf("%w: unknown scheme %q", global.ErrInvalidMessage, msg.Scheme)
+	}
+	if pub == nil {
+		return global.ErrSignerMismatch
+	}
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	if global.PeerIDToAddress(pid) != msg.Signer {
+		return global.ErrSignerMismatch
+	}
+	digest, err := Digest(msg)
+	if err != nil {
+		return err
+	}
+	ok, err := pub.Verify(digest, msg.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return global.ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/global"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestSigner returns a local signer of a new key of the type.
+func newTestSigner(t *testing.T, keyType int) *LocalSigner {
+	t.Helper()
+	key, _, err := crypto.GenerateKeyPair(keyType, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewLocalSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// signedMessage returns a message of the pair signed by the signer.
+func signedMessage(t *testing.T, signer Signer, pair string, price float64) *ProtocolMessage {
+	t.Helper()
+	msg := &ProtocolMessage{MsgId: pair + "-1", Pair: pair, Price: price}
+	if _, err := msg.Sign(context.Background(), signer); err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	return msg
+}
+
+func TestSigningPayload(t *testing.T) {
+	msg := &ProtocolMessage{
+		Version:    SigningVersion,
+		MsgId:      "id",
+		Pair:       "ETH/USD",
+		Price:      1234.5,
+		Signer:     common.HexToAddress("0x00000000000000000000000000000000000000ff"),
+		SignedTime: time.Unix(1700000000, 0),
+	}
+	payload, err := SigningPayload(msg)
+	if err != nil {
+		t.Fatalf("SigningPayload() error: %v", err)
+	}
+
+	var want []byte
+	want = append(want, 1, 0, 7)
+	want = append(want, "ETH/USD"...)
+	want = append(want, 0, 2)
+	want = append(want, "id"...)
+	want = binary.BigEndian.AppendUint64(want, math.Float64bits(1234.5))
+	want = binary.BigEndian.AppendUint64(want, 1700000000)
+	want = append(want, msg.Signer.Bytes()...)
+	if !bytes.Equal(payload, want) {
+		t.Errorf("SigningPayload() = %x, want %x", payload, want)
+	}
+
+	msg.Version = 2
+	if _, err = SigningPayload(msg); !errors.Is(err, global.ErrUnsupportedVersion) {
+		t.Errorf("SigningPayload() of version 2 error = %v, want %v", err, global.ErrUnsupportedVersion)
+	}
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	other := newTestSigner(t, crypto.Ed25519)
+
+	tests := []struct {
+		name   string
+		modify func(msg *ProtocolMessage)
+		pub    crypto.PubKey
+		want   error
+	}{
+		{"valid", func(*ProtocolMessage) {}, signer.PublicKey(), nil},
+		{"price", func(msg *ProtocolMessage) { msg.Price++ }, signer.PublicKey(), global.ErrInvalidSignature},
+		{"pair", func(msg *ProtocolMessage) { msg.Pair = "BTC/USD" }, signer.PublicKey(), global.ErrInvalidSignature},
+		{"message id", func(msg *ProtocolMessage) { msg.MsgId = "ETH/USD-2" }, signer.PublicKey(), global.ErrInvalidSignature},
+		{"signed time", func(msg *ProtocolMessage) { msg.SignedTime = msg.SignedTime.Add(time.Second) }, signer.PublicKey(), global.ErrInvalidSignature},
+		// The trigger is not covered by the signature
+		{"trigger", func(msg *ProtocolMessage) { msg.Trigger = "deviation" }, signer.PublicKey(), nil},
+		{"key of another signer", func(*ProtocolMessage) {}, other.PublicKey(), global.ErrSignerMismatch},
+		{"signer of another key", func(msg *ProtocolMessage) { msg.Signer = other.Address() }, other.PublicKey(), global.ErrInvalidSignature},
+		{"without key", func(*ProtocolMessage) {}, nil, global.ErrSignerMismatch},
+		{"unknown scheme", func(msg *ProtocolMessage) { msg.Scheme = "rsa" }, signer.PublicKey(), global.ErrInvalidMessage},
+		{"version", func(msg *ProtocolMessage) { msg.Version = 2 }, signer.PublicKey(), global.ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := signedMessage(t, signer, "ETH/USD", 2001.5)
+			if msg.Scheme != SchemeEd25519 || msg.Signer != signer.Address() {
+				t.Fatalf("Sign() = %s message of %s, want %s message of %s", msg.Scheme, msg.Signer, SchemeEd25519, signer.Address())
+			}
+			tt.modify(msg)
+			if err := Verify(msg, tt.pub); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalSignerRejectsOtherMessages(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	msg := signedMessage(t, signer, "ETH/USD", 2001.5)
+	msg.Signer = newTestSigner(t, crypto.Ed25519).Address()
+	if _, err := signer.Sign(context.Background(), msg); err == nil {
+		t.Error("Sign() of a message of another signer error = nil, want an error")
+	}
+}
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+	"github.com/multiformats/go-multiaddr"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mdnsServiceName is the mDNS service name of the nodes, so they do not
+// connect to the other libp2p applications of the local network.
+const mdnsServiceName = "_gossip-price._udp"
+
+// dhtProtocolPrefix is the protocol prefix of the DHT, so the nodes run
+// their own DHT instead of joining the public IPFS one.
+const dhtProtocolPrefix = "/gossip-price"
+
+// pubsubNamespace is the prefix which pubsub adds to the topic of the
+// discovery namespaces.
+const pubsubNamespace = "floodsub:"
+
+// connectTimeout is the timeout of connecting to a discovered peer.
+const connectTimeout = 10 * time.Second
+
+// DiscoveryConfig is the peer discovery configuration of the node.
+type DiscoveryConfig struct {
+	// Bootstrap is the addresses of the bootstrap peers. The node connects
+	// to all of them at start, and joins the DHT through them.
+	Bootstrap []multiaddr.Multiaddr
+	// Rendezvous is the prefix of the DHT rendezvous namespaces. Every
+	// subscribed topic is advertised and discovered on the
+	// <rendezvous>/<topic> namespace, so the nodes of a topic find each
+	// other without the bootstrap peers. If empty, the DHT is only used for
+	// routing.
+	Rendezvous string
+	// MDNS enables the discovery of the peers of the local network.
+	MDNS bool
+}
+
+// rendezvousDiscovery advertises and discovers the namespaces of the
+// topics under the rendezvous prefix, so networks sharing a DHT do not
+// discover each other.
+type rendezvousDiscovery struct {
+	discovery.Discovery
+	prefix string
+}
+
+// Advertise implements the discovery.Advertiser interface.
+func (d *rendezvousDiscovery) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	return d.Discovery.Advertise(ctx, d.namespace(ns), opts...)
+}
+
+// FindPeers implements the discovery.Discoverer interface.
+func (d *rendezvousDiscovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	return d.Discovery.FindPeers(ctx, d.namespace(ns), opts...)
+}
+
+func (d *rendezvousDiscovery) namespace(ns string) string {
+	return d.prefix + "/" + strings.TrimPrefix(ns, pubsubNamespace)
+}
+
+// mdnsNotifee connects to the peers found by mDNS.
+type mdnsNotifee struct {
+	node *Node
+}
+
+// HandlePeerFound implements the mdns.Notifee interface.
+func (m *mdnsNotifee) HandlePeerFound(pi peer.AddrInfo) {
+	if pi.ID == m.node.id {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(m.node.ctx, connectTimeout)
+		defer cancel()
+		if err := m.node.host.Connect(ctx, pi); err != nil {
+			log.Printf("Connecting to mDNS peer %s failed: %v", pi.ID, err)
+			return
+		}
+		log.Printf("Connected to mDNS peer %s", pi.ID)
+	}()
+}
+
+// startDHT starts the DHT of the node with the bootstrap peers, and returns
+// the pubsub option which discovers the peers of the topics on it.
+func (n *Node) startDHT() ([]pubsub.Option, error) {
+	addrs, err := peer.AddrInfosFromP2pAddrs(n.discovery.Bootstrap...)
+	if err != nil {
+		return nil, fmt.Errorf("libp2p node error, invalid bootstrap address: %w", err)
+	}
+	for _, addr := range addrs {
+		n.peerStore.AddAddrs(addr.ID, addr.Addrs, peerstore.PermanentAddrTTL)
+	}
+	n.kadDHT, err = dht.New(n.ctx, n.host,
+		dht.ProtocolPrefix(dhtProtocolPrefix),
+		dht.BootstrapPeers(addrs...),
+		dht.Mode(dht.ModeServer),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("libp2p node error, unable to initialize DHT: %w", err)
+	}
+	if n.discovery.Rendezvous == "" {
+		return nil, nil
+	}
+	return []pubsub.Option{
+		pubsub.WithDiscovery(&rendezvousDiscovery{
+			Discovery: drouting.NewRoutingDiscovery(n.kadDHT),
+			prefix:    n.discovery.Rendezvous,
+		}),
+	}, nil
+}
+
+// discover connects to the bootstrap peers, bootstraps the DHT and starts
+// the mDNS discovery. Unreachable bootstrap peers are logged, the node can
+// still find the other peers through the DHT and mDNS.
+func (n *Node) discover() error {
+	addrs, err := peer.AddrInfosFromP2pAddrs(n.discovery.Bootstrap...)
+	if err != nil {
+		return fmt.Errorf("libp2p node error, invalid bootstrap address: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		// Bootstrap peers are not protected by the DHT, so they are
+		// protected manually from the connection manager
+		n.host.ConnManager().Protect(addr.ID, "bootstrap")
+		wg.Add(1)
+		go func(pi peer.AddrInfo) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(n.ctx, connectTimeout)
+			defer cancel()
+			if err := n.host.Connect(ctx, pi); err != nil {
+				log.Printf("Connecting to bootstrap peer %s failed: %v", pi.ID, err)
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	if err = n.kadDHT.Bootstrap(n.ctx); err != nil {
+		return fmt.Errorf("libp2p node error, unable to bootstrap DHT: %w", err)
+	}
+	if n.discovery.MDNS {
+		n.mdns = mdns.NewMdnsService(n.host, mdnsServiceName, &mdnsNotifee{node: n})
+		if err = n.mdns.Start(); err != nil {
+			return fmt.Errorf("libp2p node error, unable to start mDNS: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gossip-price/core/global"
+	gomath "math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// PriceDecimals is the number of decimals of the price in the EIP-712
+// typed data.
+const PriceDecimals = 18
+
+var (
+	eip712DomainType = crypto.Keccak256([]byte(
+		"EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)",
+	))
+	priceAttestationType = crypto.Keccak256([]byte(
+		"PriceAttestation(uint8 version,string pair,string msgId,uint256 price,uint256 timestamp,address signer)",
+	))
+)
+
+// EIP712Domain is the domain of the EIP-712 typed price attestations.
+type EIP712Domain struct {
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract common.Address
+}
+
+// DefaultDomain is the domain used to sign and verify EIP-712 messages.
+var DefaultDomain = EIP712Domain{
+	Name:              global.GPEIP712Name,
+	Version:           global.GPEIP712Version,
+	ChainID:           big.NewInt(int64(global.GPChainID)),
+	VerifyingContract: defaultVerifyingContract(),
+}
+
+// defaultVerifyingContract returns GP_VERIFYINGCONTRACT, or the oracle
+// address if it's not set, because the oracle contract builds its domain
+// separator with its own address.
+func defaultVerifyingContract() common.Address {
+	if global.GPVerifyingContract != "" {
+		return common.HexToAddress(global.GPVerifyingContract)
+	}
+	return common.HexToAddress(global.GPOracleAddress)
+}
+
+// Separator returns the EIP-712 domain separator.
+func (d EIP712Domain) Separator() []byte {
+	return crypto.Keccak256(
+		eip712DomainType,
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		math.U256Bytes(new(big.Int).Set(d.ChainID)),
+		common.LeftPadBytes(d.VerifyingContract.Bytes(), 32),
+	)
+}
+
+// TypedDataHash returns the EIP-712 hash of the message, as it is signed
+// by secp256k1 signers. The message is encoded as the following struct:
+//
+//	PriceAttestation(
+//	    uint8 version,
+//	    string pair,
+//	    string msgId,
+//	    uint256 price,     // price with PriceDecimals decimals
+//	    uint256 timestamp, // signed time, unix seconds
+//	    address signer
+//	)
+func TypedDataHash(msg *ProtocolMessage, domain EIP712Domain) ([]byte, error) {
+	if msg.Version != SigningVersion {
+		return nil, fmt.Errorf("%w: %d", global.ErrUnsupportedVersion, msg.Version)
+	}
+	if msg.Price < 0 || msg.SignedTime.Unix() < 0 {
+		return nil, fmt.Errorf("%w: negative price or timestamp", global.ErrInvalidMessage)
+	}
+	price, err := PriceToWei(msg.Price)
+	if err != nil {
+		return nil, err
+	}
+	structHash := crypto.Keccak256(
+		priceAttestationType,
+		math.U256Bytes(big.NewInt(int64(msg.Version))),
+		crypto.Keccak256([]byte(msg.Pair)),
+		crypto.Keccak256([]byte(msg.MsgId)),
+		math.U256Bytes(price),
+		math.U256Bytes(big.NewInt(msg.SignedTime.Unix())),
+		common.LeftPadBytes(msg.Signer.Bytes(), 32),
+	)
+	return crypto.Keccak256([]byte("\x19\x01"), domain.Separator(), structHash), nil
+}
+
+// PriceToWei converts the price to an integer with PriceDecimals
+// decimals. The shortest decimal representation of the price is used,
+// so 1234.56 is converted to exactly 1234560000000000000000. Digits
+// beyond PriceDecimals are truncated. Prices which are not finite, or do
+// not fit in an uint256, return an error.
+func PriceToWei(price float64) (*big.Int, error) {
+	if gomath.IsNaN(price) || gomath.IsInf(price, 0) {
+		return nil, fmt.Errorf("%w: non-finite price %v", global.ErrInvalidMessage, price)
+	}
+	str := strconv.FormatFloat(price, 'f', -1, 64)
+	whole, frac, _ := strings.Cut(str, ".")
+	if len(frac) > PriceDecimals {
+		frac = frac[:PriceDecimals]
+	}
+	frac += strings.Repeat("0", PriceDecimals-len(frac))
+	wei, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok || wei.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: price %v out of range", global.ErrInvalidMessage, price)
+	}
+	return wei, nil
+}
+
+// RecoverSigner returns the address that signed the EIP-712 hash of the
+// message. The signature must be in the [R || S || V] format, where V is
+// 27 or 28.
+func RecoverSigner(msg *ProtocolMessage, domain EIP712Domain) (common.Address, error) {
+	if len(msg.Signature) != crypto.SignatureLength {
+		return common.Address{}, global.ErrInvalidSignature
+	}
+	hash, err := TypedDataHash(msg, domain)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, msg.Signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", global.ErrInvalidSignature, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,166 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/global"
+	gomath "math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var testDomain = EIP712Domain{
+	Name:              "GossipPrice",
+	Version:           "1",
+	ChainID:           big.NewInt(1),
+	VerifyingContract: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+}
+
+// TestTypedDataHash checks the hash against the EIP-712 implementation of
+// go-ethereum, which is what wallets and contracts agree on.
+func TestTypedDataHash(t *testing.T) {
+	msg := &ProtocolMessage{
+		Version:    SigningVersion,
+		MsgId:      "ETH/USD-28930211",
+		Pair:       "ETH/USD",
+		Price:      2001.25,
+		Signer:     common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		SignedTime: time.Unix(1700000000, 0),
+	}
+	got, err := TypedDataHash(msg, testDomain)
+	if err != nil {
+		t.Fatalf("TypedDataHash() error: %v", err)
+	}
+
+	typed := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"PriceAttestation": {
+				{Name: "version", Type: "uint8"},
+				{Name: "pair", Type: "string"},
+				{Name: "msgId", Type: "string"},
+				{Name: "price", Type: "uint256"},
+				{Name: "timestamp", Type: "uint256"},
+				{Name: "signer", Type: "address"},
+			},
+		},
+		PrimaryType: "PriceAttestation",
+		Domain: apitypes.TypedDataDomain{
+			Name:              testDomain.Name,
+			Version:           testDomain.Version,
+			ChainId:           (*math.HexOrDecimal256)(testDomain.ChainID),
+			VerifyingContract: testDomain.VerifyingContract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"version":   "1",
+			"pair":      msg.Pair,
+			"msgId":     msg.MsgId,
+			"price":     "2001250000000000000000",
+			"timestamp": "1700000000",
+			"signer":    msg.Signer.Hex(),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("TypedDataHash() = %x, want %x", got, want)
+	}
+}
+
+func TestPriceToWei(t *testing.T) {
+	tests := []struct {
+		price float64
+		want  string
+		err   bool
+	}{
+		{1234.56, "1234560000000000000000", false},
+		{0.1, "100000000000000000", false},
+		{0, "0", false},
+		{1e-19, "0", false},
+		{1e21, "1000000000000000000000000000000000000000", false},
+		{gomath.NaN(), "", true},
+		{gomath.Inf(1), "", true},
+		{gomath.Inf(-1), "", true},
+		// More than 256 bits with the decimals
+		{1e60, "", true},
+	}
+	for _, tt := range tests {
+		got, err := PriceToWei(tt.price)
+		if tt.err {
+			if !errors.Is(err, global.ErrInvalidMessage) {
+				t.Errorf("PriceToWei(%v) error = %v, want %v", tt.price, err, global.ErrInvalidMessage)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("PriceToWei(%v) = %v, %v, want %s", tt.price, got, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyEIP712(t *testing.T) {
+	signer := newTestSigner(t, crypto.Secp256k1)
+
+	tests := []struct {
+		name   string
+		modify func(msg *ProtocolMessage)
+		want   error
+	}{
+		{"valid", func(*ProtocolMessage) {}, nil},
+		{"price", func(msg *ProtocolMessage) { msg.Price++ }, global.ErrSignerMismatch},
+		{"pair", func(msg *ProtocolMessage) { msg.Pair = "BTC/USD" }, global.ErrSignerMismatch},
+		{"signed time", func(msg *ProtocolMessage) { msg.SignedTime = msg.SignedTime.Add(time.Second) }, global.ErrSignerMismatch},
+		{"signer", func(msg *ProtocolMessage) { msg.Signer = common.HexToAddress("0x01") }, global.ErrSignerMismatch},
+		{"short signature", func(msg *ProtocolMessage) { msg.Signature = msg.Signature[:64] }, global.ErrInvalidSignature},
+		{"non-finite price", func(msg *ProtocolMessage) { msg.Price = gomath.NaN() }, global.ErrInvalidMessage},
+		{"negative price", func(msg *ProtocolMessage) { msg.Price = -1 }, global.ErrInvalidMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := signedMessage(t, signer, "ETH/USD", 2001.5)
+			if msg.Scheme != SchemeEIP712 || len(msg.Signature) != 65 || msg.Signature[64] < 27 {
+				t.Fatalf("Sign() = %s message with signature %x, want an EIP-712 signature with V 27 or 28", msg.Scheme, msg.Signature)
+			}
+			tt.modify(msg)
+			// The signer is recovered, no public key is needed
+			if err := Verify(msg, nil); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverSignerOtherDomain(t *testing.T) {
+	signer := newTestSigner(t, crypto.Secp256k1)
+	msg := signedMessage(t, signer, "ETH/USD", 2001.5)
+
+	if addr, err := RecoverSigner(msg, DefaultDomain); err != nil || addr != signer.Address() {
+		t.Fatalf("RecoverSigner() = %s, %v, want %s", addr, err, signer.Address())
+	}
+	other := DefaultDomain
+	other.ChainID = new(big.Int).Add(DefaultDomain.ChainID, big.NewInt(1))
+	if addr, err := RecoverSigner(msg, other); err == nil && addr == signer.Address() {
+		t.Error("RecoverSigner() recovered the signer with another chain id")
+	}
+}
+
+func TestSignNonFinitePrice(t *testing.T) {
+	signer := newTestSigner(t, crypto.Secp256k1)
+	msg := &ProtocolMessage{MsgId: "ETH/USD-1", Pair: "ETH/USD", Price: gomath.Inf(1)}
+	if _, err := msg.Sign(context.Background(), signer); !errors.Is(err, global.ErrInvalidMessage) {
+		t.Errorf("Sign() error = %v, want %v", err, global.ErrInvalidMessage)
+	}
+}
@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"gossip-price/core/metrics"
+	"net"
+	"os"
+	"strings"
+)
+
+// GaterConfig is the configuration of the connection gater. Deny lists
+// take precedence over allow lists, and an empty allow list allows all
+// peers or addresses which are not denied.
+type GaterConfig struct {
+	AllowPeers []string
+	DenyPeers  []string
+	AllowCIDRs []string
+	DenyCIDRs  []string
+}
+
+// Empty returns true if no list is configured.
+func (c GaterConfig) Empty() bool {
+	return len(c.AllowPeers) == 0 && len(c.DenyPeers) == 0 && len(c.AllowCIDRs) == 0 && len(c.DenyCIDRs) == 0
+}
+
+// Gater implements the libp2p connmgr.ConnectionGater interface. It allows
+// or denies the connections by the peer ID and by the IP address of the
+// remote peer. Addresses without an IP address, e.g. DNS addresses, are
+// only allowed if no CIDR allow list is configured.
+type Gater struct {
+	allowPeers map[peer.ID]bool
+	denyPeers  map[peer.ID]bool
+	allowNets  []*net.IPNet
+	denyNets   []*net.IPNet
+}
+
+// NewGater returns a new gater of the configuration.
+func NewGater(c GaterConfig) (*Gater, error) {
+	g := &Gater{}
+	var err error
+	if g.allowPeers, err = parsePeerIDs(c.AllowPeers); err != nil {
+		return nil, err
+	}
+	if g.denyPeers, err = parsePeerIDs(c.DenyPeers); err != nil {
+		return nil, err
+	}
+	if g.allowNets, err = parseCIDRs(c.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if g.denyNets, err = parseCIDRs(c.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// InterceptPeerDial implements the connmgr.ConnectionGater interface.
+func (g *Gater) InterceptPeerDial(p peer.ID) bool {
+	return g.allowPeer(p)
+}
+
+// InterceptAddrDial implements the connmgr.ConnectionGater interface.
+func (g *Gater) InterceptAddrDial(p peer.ID, addr multiaddr.Multiaddr) bool {
+	return g.allowPeer(p) && g.allowAddr(addr)
+}
+
+// InterceptAccept implements the connmgr.ConnectionGater interface. The
+// peer ID is not known yet, so only the address is checked.
+func (g *Gater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return g.allowAddr(addrs.RemoteMultiaddr())
+}
+
+// InterceptSecured implements the connmgr.ConnectionGater interface.
+func (g *Gater) InterceptSecured(_ network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	return g.allowPeer(p) && g.allowAddr(addrs.RemoteMultiaddr())
+}
+
+// InterceptUpgraded implements the connmgr.ConnectionGater interface.
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// allowPeer returns true if the peer is allowed by the peer lists.
+func (g *Gater) allowPeer(p peer.ID) bool {
+	if g.denyPeers[p] || (len(g.allowPeers) > 0 && !g.allowPeers[p]) {
+		metrics.ConnectionsDenied.WithLabelValues("peer").Inc()
+		return false
+	}
+	return true
+}
+
+// allowAddr returns true if the address is allowed by the CIDR lists.
+func (g *Gater) allowAddr(addr multiaddr.Multiaddr) bool {
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		if len(g.allowNets) > 0 {
+			metrics.ConnectionsDenied.WithLabelValues("address").Inc()
+			return false
+		}
+		return true
+	}
+	if containsIP(g.denyNets, ip) || (len(g.allowNets) > 0 && !containsIP(g.allowNets, ip)) {
+		metrics.ConnectionsDenied.WithLabelValues("address").Inc()
+		return false
+	}
+	return true
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePeerIDs(ids []string) (map[peer.ID]bool, error) {
+	res := make(map[peer.ID]bool, len(ids))
+	for _, s := range ids {
+		id, err := peer.Decode(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("connection gater error, invalid peer ID %q: %w", s, err)
+		}
+		res[id] = true
+	}
+	return res, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("connection gater error, invalid CIDR %q: %w", s, err)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// LoadPSK reads the pre-shared key of a private network from the file. The
+// file uses the swarm.key format of IPFS:
+//
+//	/key/swarm/psk/1.0.0/
+//	/base16/
+//	<64 hex characters>
+func LoadPSK(path string) (pnet.PSK, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("private network error, unable to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	psk, err := pnet.DecodeV1PSK(file)
+	if err != nil {
+		return nil, fmt.Errorf("private network error, invalid key in %s: %w", path, err)
+	}
+	return psk, nil
+}
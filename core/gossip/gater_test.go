@@ -0,0 +1,187 @@
+package protocol
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/multiformats/go-multiaddr"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPeer returns the ID of a new peer.
+func newTestPeer(t *testing.T) peer.ID {
+	t.Helper()
+	_, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestGater(t *testing.T) {
+	allowed, denied, other := newTestPeer(t), newTestPeer(t), newTestPeer(t)
+	local := multiaddr.StringCast("/ip4/10.0.0.5/tcp/4001")
+	remote := multiaddr.StringCast("/ip4/203.0.113.7/tcp/4001")
+	blocked := multiaddr.StringCast("/ip4/10.0.9.1/tcp/4001")
+	dns := multiaddr.StringCast("/dns4/example.com/tcp/4001")
+
+	tests := []struct {
+		name string
+		cfg  GaterConfig
+		peer peer.ID
+		addr multiaddr.Multiaddr
+		want bool
+	}{
+		{"empty", GaterConfig{}, other, remote, true},
+		{"allowed peer", GaterConfig{AllowPeers: []string{allowed.String()}}, allowed, remote, true},
+		{"not allowed peer", GaterConfig{AllowPeers: []string{allowed.String()}}, other, remote, false},
+		{"denied peer", GaterConfig{DenyPeers: []string{denied.String()}}, denied, remote, false},
+		{"not denied peer", GaterConfig{DenyPeers: []string{denied.String()}}, other, remote, true},
+		// Deny lists take precedence over allow lists
+		{"allowed and denied peer", GaterConfig{AllowPeers: []string{denied.String()}, DenyPeers: []string{denied.String()}}, denied, remote, false},
+		{"allowed address", GaterConfig{AllowCIDRs: []string{"10.0.0.0/8"}}, other, local, true},
+		{"not allowed address", GaterConfig{AllowCIDRs: []string{"10.0.0.0/8"}}, other, remote, false},
+		{"denied address", GaterConfig{AllowCIDRs: []string{"10.0.0.0/8"}, DenyCIDRs: []string{"10.0.9.0/24"}}, other, blocked, false},
+		{"not denied address", GaterConfig{DenyCIDRs: []string{"10.0.9.0/24"}}, other, local, true},
+		{"dns address", GaterConfig{DenyCIDRs: []string{"10.0.9.0/24"}}, other, dns, true},
+		{"dns address with allow list", GaterConfig{AllowCIDRs: []string{"10.0.0.0/8"}}, other, dns, false},
+		{"allowed peer of denied address", GaterConfig{AllowPeers: []string{allowed.String()}, DenyCIDRs: []string{"203.0.113.0/24"}}, allowed, remote, false},
+	}
+	for _, tt := range tests {
+		g, err := NewGater(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: NewGater() error: %v", tt.name, err)
+		}
+		if got := g.InterceptAddrDial(tt.peer, tt.addr); got != tt.want {
+			t.Errorf("%s: InterceptAddrDial() = %t, want %t", tt.name, got, tt.want)
+		}
+		if got := g.InterceptPeerDial(tt.peer) && g.allowAddr(tt.addr); got != tt.want {
+			t.Errorf("%s: InterceptPeerDial() and the address = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewGaterInvalid(t *testing.T) {
+	for _, cfg := range []GaterConfig{
+		{AllowPeers: []string{"peer"}},
+		{DenyPeers: []string{""}},
+		{AllowCIDRs: []string{"10.0.0.0"}},
+		{DenyCIDRs: []string{"10.0.0.0/33"}},
+	} {
+		if _, err := NewGater(cfg); err == nil {
+			t.Errorf("NewGater(%+v) error = nil, want an error", cfg)
+		}
+	}
+	if !(GaterConfig{}).Empty() || (GaterConfig{DenyCIDRs: []string{"10.0.0.0/8"}}).Empty() {
+		t.Error("Empty() does not match the configured lists")
+	}
+}
+
+// writePSK writes a swarm.key file of the key and returns its path.
+func writePSK(t *testing.T, key string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "swarm.key")
+	if err := os.WriteFile(path, []byte("/key/swarm/psk/1.0.0/\n/base16/\n"+key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPSK(t *testing.T) {
+	key := strings.Repeat("0123456789abcdef", 4)
+	psk, err := LoadPSK(writePSK(t, key))
+	if err != nil {
+		t.Fatalf("LoadPSK() error: %v", err)
+	}
+	if hex.EncodeToString(psk) != key {
+		t.Errorf("LoadPSK() = %x, want %s", []byte(psk), key)
+	}
+	if _, err := LoadPSK(writePSK(t, "0123")); err == nil {
+		t.Error("LoadPSK() of a short key error = nil, want an error")
+	}
+	if _, err := LoadPSK(filepath.Join(t.TempDir(), "missing.key")); err == nil {
+		t.Error("LoadPSK() of a missing file error = nil, want an error")
+	}
+}
+
+// newGatedHost returns a host listening on localhost, in the private network
+// of the key if it's not empty, with the connection gater of cfg.
+func newGatedHost(t *testing.T, psk pnet.PSK, cfg GaterConfig) host.Host {
+	t.Helper()
+	opts := []libp2p.Option{libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")}
+	if len(psk) > 0 {
+		opts = append(opts, libp2p.PrivateNetwork(psk))
+	}
+	if !cfg.Empty() {
+		g, err := NewGater(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, libp2p.ConnectionGater(g))
+	}
+	h, err := libp2p.New(opts...)
+	if err != nil {
+		t.Fatalf("libp2p.New() error: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// connects returns true if the client can connect to the server, and ping
+// it over the connection. The server may close a connection it denies only
+// after the client considers it connected.
+func connects(client, server host.Host) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx, peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}); err != nil {
+		return false
+	}
+	res := <-ping.Ping(ctx, client, server.ID())
+	return res.Error == nil
+}
+
+func TestPrivateNetworkAndGater(t *testing.T) {
+	psk := pnet.PSK([]byte(strings.Repeat("k", 32)))
+	other := pnet.PSK([]byte(strings.Repeat("o", 32)))
+
+	denied := newGatedHost(t, psk, GaterConfig{})
+	tests := []struct {
+		name string
+		host host.Host
+		want bool
+	}{
+		{"same key", newGatedHost(t, psk, GaterConfig{}), true},
+		{"other key", newGatedHost(t, other, GaterConfig{}), false},
+		{"without key", newGatedHost(t, nil, GaterConfig{}), false},
+		// The client does not dial the addresses it denies
+		{"denied by the client", newGatedHost(t, psk, GaterConfig{DenyCIDRs: []string{"127.0.0.0/8"}}), false},
+		{"not allowed by the server", denied, false},
+	}
+	// The server allows all peers of the test but one
+	var allow []string
+	for _, tt := range tests {
+		if tt.host != denied {
+			allow = append(allow, tt.host.ID().String())
+		}
+	}
+	server := newGatedHost(t, psk, GaterConfig{AllowPeers: allow})
+
+	for _, tt := range tests {
+		if got := connects(tt.host, server); got != tt.want {
+			t.Errorf("%s: connected = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,11 @@
 package protocol
 
 import (
-	"encoding/binary"
+	"context"
 	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/libp2p/go-libp2p/core/crypto"
-	"github.com/libp2p/go-libp2p/core/peer"
-	common2 "gossip-price/core/global"
-	"math"
 	"time"
 )
 
@@ -54,67 +52,107 @@ type SignedMessage interface {
 }
 
 type UnsignedMessage interface {
-	Sign(key crypto.PrivKey) (SignedMessage, error)
+	Sign(ctx context.Context, signer Signer) (SignedMessage, error)
 }
 
 type Transport interface {
-	Broadcast(message UnsignedMessage) (SignedMessage, error)
+	Broadcast(title string, message UnsignedMessage) (SignedMessage, error)
 	Message() <-chan ReceivedMessage
 }
 
 type ProtocolMessage struct {
+	Version    uint8
+	Scheme     string
 	MsgId      string
+	Pair       string
 	Price      float64
 	Signer     common.Address
 	Signature  Signature
 	SignedTime time.Time
+	// Trigger is the reason the round of the message was started. It's
+	// informational only and it's not covered by the signature.
+	Trigger string
 }
 
 func (p ProtocolMessage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]any{
+		"version":     p.Version,
+		"scheme":      p.Scheme,
 		"id":          p.MsgId,
+		"pair":        p.Pair,
 		"price":       p.Price,
 		"signer":      p.Signer,
 		"signature":   p.Signature,
 		"signed_time": p.SignedTime,
+		"trigger":     p.Trigger,
 	})
 }
 
 func (p *ProtocolMessage) UnmarshalJSON(data []byte) error {
 	var temp struct {
+		Version    uint8          `json:"version"`
+		Scheme     string         `json:"scheme"`
 		ID         string         `json:"id"`
+		Pair       string         `json:"pair"`
 		Price      float64        `json:"price"`
 		Signer     common.Address `json:"signer"`
 		Signature  Signature      `json:"signature"`
 		SignedTime string         `json:"signed_time"`
+		Trigger    string         `json:"trigger"`
 	}
 	err := json.Unmarshal(data, &temp)
 	if err != nil {
 		return err
 	}
+	p.Version = temp.Version
+	p.Scheme = temp.Scheme
 	p.MsgId = temp.ID
+	p.Pair = temp.Pair
 	p.Price = temp.Price
 	p.Signer = temp.Signer
 	p.Signature = temp.Signature
 	p.SignedTime, _ = time.Parse(time.RFC3339, temp.SignedTime)
+	p.Trigger = temp.Trigger
 	return nil
 }
 
-func (p *ProtocolMessage) Sign(key crypto.PrivKey) (SignedMessage, error) {
-	datetime := time.Now()
-	priceData := make([]byte, 8)
-	binary.BigEndian.PutUint64(priceData, math.Float64bits(p.Price))
+// Sign signs the message with the signer. The version, the scheme, the
+// signer address and the signed time are set before the signature is
+// requested, as they are covered by it.
+func (p *ProtocolMessage) Sign(ctx context.Context, signer Signer) (SignedMessage, error) {
+	p.Version = SigningVersion
+	p.Scheme = signer.Scheme()
+	p.Signer = signer.Address()
+	// The signed time is stored with second precision, as in the digest
+	p.SignedTime = time.Unix(time.Now().Unix(), 0)
 
-	bytes, err := key.Sign(priceData)
+	sig, err := signer.Sign(ctx, p)
 	if err != nil {
 		return nil, err
 	}
-	pid, err := peer.IDFromPublicKey(key.GetPublic())
+	p.Signature = sig
+	return p, nil
+}
+
+// signEIP712 returns the [R || S || V] signature of the EIP-712 hash of
+// the message.
+func signEIP712(p *ProtocolMessage, key crypto.PrivKey) (Signature, error) {
+	raw, err := key.Raw()
 	if err != nil {
 		return nil, err
 	}
-	p.Signer = common2.PeerIDToAddress(pid)
-	p.Signature = bytes
-	p.SignedTime = datetime
-	return p, nil
+	ecdsaKey, err := ethcrypto.ToECDSA(raw)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := TypedDataHash(p, DefaultDomain)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ethcrypto.Sign(hash, ecdsaKey)
+	if err != nil {
+		return nil, err
+	}
+	sig[ethcrypto.RecoveryIDOffset] += 27
+	return sig, nil
 }
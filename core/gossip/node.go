@@ -7,31 +7,51 @@ import (
 	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
-	"github.com/libp2p/go-libp2p/core"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
 	"gossip-price/core/global"
+	"gossip-price/core/metrics"
 	"log"
 	"sync"
 	"time"
 )
 
+// Validator validates a message of a topic. It may return Accept, Reject
+// or Ignore. Validators which run after the schema validator can get the
+// unmarshalled message with MessageOf.
 type Validator func(ctx context.Context, topic string, id peer.ID, msg *pubsub.Message) pubsub.ValidationResult
 
+// namedValidator is a validator of the set with its name, used as the
+// reason in the metrics. An empty topic matches all topics.
+type namedValidator struct {
+	name      string
+	topic     string
+	validator Validator
+}
+
 // ValidatorSet stores multiple instances of validators that implements
-// the pubsub.ValidatorEx functions. Validators are groped by topic.
+// the pubsub.ValidatorEx functions. Validators are grouped by topic, and
+// they run in the order they were added.
 type ValidatorSet struct {
-	validators []Validator
+	mu         sync.RWMutex
+	validators []namedValidator
 }
 
 type NodeConfig struct {
 	Options []libp2p.Option
-	Title   string
 	NodeKey crypto.PrivKey
+	// PeerScore enables the GossipSub peer scoring. If nil, peers are not
+	// scored.
+	PeerScore *ScoreConfig
+	// Discovery is the peer discovery configuration.
+	Discovery DiscoveryConfig
 }
 
 // Node is a single node in the P2P network. It wraps the libp2p library to
@@ -50,9 +70,15 @@ type Node struct {
 	closed        bool
 	peerStore     peerstore.Peerstore
 	validatorSet  *ValidatorSet
-
-	hostOpts []libp2p.Option
-	//pubsubOpts []pubsub.Option
+	peerScore     *ScoreConfig
+	scoresMu      sync.Mutex
+	scores        map[peer.ID]*pubsub.PeerScoreSnapshot
+	discovery     DiscoveryConfig
+	kadDHT        *dht.IpfsDHT
+	mdns          mdns.Service
+
+	hostOpts   []libp2p.Option
+	pubsubOpts []pubsub.Option
 }
 
 func NewNode(config NodeConfig) (*Node, error) {
@@ -70,12 +96,26 @@ func NewNode(config NodeConfig) (*Node, error) {
 	}
 
 	n := &Node{
-		id:        pid,
-		waitCh:    make(chan error),
-		peerStore: ps,
-		subs:      make(map[string]*Subscription),
-		closed:    false,
-		hostOpts:  config.Options,
+		id:           pid,
+		waitCh:       make(chan error),
+		peerStore:    ps,
+		subs:         make(map[string]*Subscription),
+		closed:       false,
+		hostOpts:     config.Options,
+		validatorSet: &ValidatorSet{},
+		peerScore:    config.PeerScore,
+		discovery:    config.Discovery,
+	}
+	if config.PeerScore != nil {
+		if err := config.PeerScore.Validate(); err != nil {
+			return nil, err
+		}
+		// The inspect option must be set after the score option
+		params, thresholds := config.PeerScore.params()
+		n.pubsubOpts = append(n.pubsubOpts,
+			pubsub.WithPeerScore(params, thresholds),
+			pubsub.WithPeerScoreInspect(pubsub.ExtendedPeerScoreInspectFn(n.inspectScores), scoreInspectInterval),
+		)
 	}
 	return n, nil
 }
@@ -103,15 +143,26 @@ func (n *Node) Start(ctx context.Context) error {
 	}
 	log.Printf("Node address: %s", global.PeerIDToAddress(n.id))
 
+	// The DHT must be started before the pubsub, which discovers the peers
+	// of the topics on it
+	discoveryOpts, err := n.startDHT()
+	if err != nil {
+		return err
+	}
 	if !n.disablePubSub {
 		options := []pubsub.Option{
 			pubsub.WithMessageAuthor(n.id),
 		}
+		options = append(options, n.pubsubOpts...)
+		options = append(options, discoveryOpts...)
 		n.pubSub, err = pubsub.NewGossipSub(n.ctx, n.host, options...)
 		if err != nil {
 			return fmt.Errorf("libp2p node error, unable to initialize gosspib pubsub: %w", err)
 		}
 	}
+	if err = n.discover(); err != nil {
+		return err
+	}
 
 	go n.ShowConnectedNode()
 	return nil
@@ -171,7 +222,7 @@ func (n *Node) Subscribe(topic string) (*Subscription, error) {
 		return nil, fmt.Errorf("libp2p node error: %w", global.ErrAlreadySubscribed)
 	}
 
-	sub, err := newSubscription(n, topic, n.validator(topic))
+	sub, err := newSubscription(n, topic, n.validatorSet.Validator(topic))
 	if err != nil {
 		return nil, err
 	}
@@ -224,42 +275,29 @@ func (n *Node) contextCancelHandler() {
 
 	n.subs = nil
 	n.closed = true
+	if n.mdns != nil {
+		_ = n.mdns.Close()
+	}
+	if n.kadDHT != nil {
+		_ = n.kadDHT.Close()
+	}
 	err := n.host.Close()
 	if err != nil {
 		n.waitCh <- err
 	}
 }
 
-func (n *Node) AddValidator(validator Validator) {
+// AddValidator adds the validator of the topic to the validator pipeline,
+// or of all topics if topic is empty. Validators must be added before the
+// node is started.
+func (n *Node) AddValidator(name string, topic string, validator Validator) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	n.validatorSet.Add(validator)
-}
-
-func (n *Node) Discovery(maddrs []core.Multiaddr) error {
-	var err error
-	var kadDHT *dht.IpfsDHT
-
-	addrs, err := peer.AddrInfosFromP2pAddrs(maddrs...)
-	if err != nil {
-		return err
-	}
-
-	for _, addr := range addrs {
-		// Bootstrap nodes are not protected by KAD-DHT, so we have
-		// done it manually.
-		//n.connmgr.Protect(addr.ID, "bootstrap")
-		n.peerStore.AddAddrs(addr.ID, addr.Addrs, peerstore.PermanentAddrTTL)
-	}
-	kadDHT, err = dht.New(n.ctx, n.host, dht.BootstrapPeers(addrs...), dht.Mode(dht.ModeServer))
-	if err != nil {
-		return nil
-	}
-	if err = kadDHT.Bootstrap(n.ctx); err != nil {
-		return nil
+	if n.ctx != nil {
+		return errors.New("validators must be added before the node is started")
 	}
-	//n.pubsubOpts = append(n.pubsubOpts, pubsub.WithDiscovery(routing.NewRoutingDiscovery(kadDHT)))
+	n.validatorSet.Add(name, topic, validator)
 	return nil
 }
 
@@ -272,32 +310,37 @@ func (n *Node) ConnecetedAddressStrings() []string {
 	return strs
 }
 
-// validator validates message of specific topic, and returns true or false
-func (n *Node) validator(topic string) pubsub.Validator {
-	return func(ctx context.Context, id peer.ID, psMsg *pubsub.Message) bool {
-		// Validator unmarshalls messages, and unmarshalled message is stored in ValidatorData field
-		// which will be used when receives messages
-		msg := &ProtocolMessage{}
-		err := msg.UnmarshalJSON(psMsg.Data)
-		if err != nil {
-			return false
-		}
-		psMsg.ValidatorData = msg
-		return true
-	}
-}
+// Add adds a new validator of the topic to the set. If topic is empty,
+// the validator runs for all topics.
+func (n *ValidatorSet) Add(name string, topic string, validator Validator) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 
-// Add adds new pubsub.ValidatorEx to the set.
-func (n *ValidatorSet) Add(validator ...Validator) {
-	n.validators = append(n.validators, validator...)
+	n.validators = append(n.validators, namedValidator{name: name, topic: topic, validator: validator})
 }
 
 // Validator returns function that implements pubsub.ValidatorEx. That function
-// will invoke all registered validators for given topic.
+// will invoke all registered validators for given topic, until one of them
+// does not accept the message.
 func (n *ValidatorSet) Validator(title string) pubsub.ValidatorEx {
 	return func(ctx context.Context, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
-		for _, validator := range n.validators {
-			if result := validator(ctx, title, id, psMsg); result != pubsub.ValidationAccept {
+		defer prometheus.NewTimer(metrics.ValidatorLatency.WithLabelValues(title)).ObserveDuration()
+
+		n.mu.RLock()
+		defer n.mu.RUnlock()
+
+		for _, v := range n.validators {
+			if v.topic != "" && v.topic != title {
+				continue
+			}
+			switch result := v.validator(ctx, title, id, psMsg); result {
+			case pubsub.ValidationAccept:
+				continue
+			case pubsub.ValidationIgnore:
+				metrics.MessagesIgnored.WithLabelValues(title, v.name).Inc()
+				return result
+			default:
+				metrics.MessagesRejected.WithLabelValues(title, v.name).Inc()
 				return result
 			}
 		}
@@ -305,12 +348,35 @@ func (n *ValidatorSet) Validator(title string) pubsub.ValidatorEx {
 	}
 }
 
+// Validate runs the validators of the topic on a message which is not
+// received by gossip, e.g. synced from a peer, as if it was published by
+// the author. It returns an error if the message is rejected or ignored.
+func (n *ValidatorSet) Validate(ctx context.Context, title string, author peer.ID, msg *ProtocolMessage) error {
+	data, err := msg.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("%w: %v", global.ErrInvalidMessage, err)
+	}
+	psMsg := &pubsub.Message{
+		Message:      &pb.Message{Data: data, From: []byte(author), Topic: &title},
+		ReceivedFrom: author,
+	}
+	switch n.Validator(title)(ctx, author, psMsg) {
+	case pubsub.ValidationAccept:
+		return nil
+	case pubsub.ValidationIgnore:
+		return fmt.Errorf("%w: message(%s) is ignored by the validators of %s", global.ErrInvalidMessage, msg.MsgId, title)
+	default:
+		return fmt.Errorf("%w: message(%s) is rejected by the validators of %s", global.ErrInvalidMessage, msg.MsgId, title)
+	}
+}
+
 func (n *Node) ShowConnectedNode() {
 	var oldPeers = 0
 	for {
 		select {
 		case <-time.After(time.Second * 10):
 			peers := n.Host().Network().Peers()
+			metrics.Peers.Set(float64(len(peers)))
 			if len(peers) != oldPeers {
 				log.Printf("Conection counts updated:%d", len(peers))
 			}
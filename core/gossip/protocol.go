@@ -6,14 +6,19 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	"github.com/multiformats/go-multiaddr"
 	"gossip-price/core/global"
-	"strings"
+	"gossip-price/core/metrics"
+	"gossip-price/core/registry"
+	"sync"
 	"time"
 )
 
@@ -35,14 +40,47 @@ type Config struct {
 	// listening on. If empty, the localhost, and a random port will be used.
 	ConnectedAddress []string
 	// BootstrapAddress is a list multiaddresses of initial peers to connect to.
-	// This option is ignored when discovery is disabled.
 	BootstrapAddress []string
+	// Rendezvous is the prefix of the DHT rendezvous namespaces of the
+	// topics. If empty, the peers are not discovered on the DHT.
+	Rendezvous string
+	// MDNS enables the discovery of the peers of the local network.
+	MDNS bool
 	// NodeKey is a key used for peer identity and sign message. If empty, then random key
-	// is used. Ignored in bootstrap mode.
+	// is used, so the peer ID and the signer address change on every start. It's
+	// required in bootstrap mode.
 	NodeKey crypto.PrivKey
-	// Titles is a list of subscribed topics. A value of the map a type of
-	// message given as a nil pointer, e.g.: (*Message)(nil).
-	Titles string
+	// Scheme is the signing scheme used to generate a random NodeKey. With
+	// SchemeEIP712 a secp256k1 key is generated, so the node signs EIP-712
+	// messages with a recoverable Ethereum address.
+	Scheme string
+	// Signer signs the broadcast messages. If nil, the messages are signed
+	// by the NodeKey.
+	Signer Signer
+	// Signers is the registry of authorized signers. Messages of other
+	// signers are rejected by the topic validator. If nil, any signer is
+	// accepted.
+	Signers *registry.Registry
+	// MaxMessageAge is the age of the signed time after which messages are
+	// ignored. Zero disables the check.
+	MaxMessageAge time.Duration
+	// MaxClockSkew is how far in the future the signed time of a message
+	// may be before the message is rejected.
+	MaxClockSkew time.Duration
+	// PriceBounds are the accepted prices of the pairs. Messages of other
+	// pairs are only checked to have a positive price.
+	PriceBounds map[string]PriceBounds
+	// PeerScore enables the GossipSub peer scoring. If nil, peers are not
+	// scored.
+	PeerScore *ScoreConfig
+	// PSK is the pre-shared key of a private network. Only the nodes with
+	// the same key can connect to each other. If empty, the network is
+	// public.
+	PSK pnet.PSK
+	// Gater is the peer ID and CIDR allow and deny lists of the connections.
+	Gater GaterConfig
+	// Titles is a list of subscribed topics.
+	Titles []string
 	// IsBootstrap is a flag used for identify if it's bootstrap or not
 	IsBootstrap bool
 }
@@ -51,9 +89,10 @@ type Config struct {
 type Protocol struct {
 	id          peer.ID
 	node        *Node
-	titles      string
+	signerMu    sync.RWMutex
+	signer      Signer
+	titles      []string
 	isBootstrap bool
-	bootAddress []string
 	msgCh       chan ReceivedMessage
 }
 
@@ -62,11 +101,19 @@ type Protocol struct {
 func New(c Config) (*Protocol, error) {
 	var err error
 	if c.NodeKey == nil {
-		seed := rand.Reader
+		// The peer ID of the bootstrap node is part of the bootstrap address
+		// of the other nodes, so it must not change on restart
 		if c.IsBootstrap {
-			seed = strings.NewReader("QmdErMiygrmkPsLTxzLTNEq5p4kCXSx26encEBkdoYRsGJ")
+			return nil, fmt.Errorf("P2P protocol error, bootstrap node requires a persistent key")
+		}
+		switch c.Scheme {
+		case SchemeEIP712:
+			c.NodeKey, _, err = crypto.GenerateSecp256k1Key(rand.Reader)
+		case SchemeEd25519, "":
+			c.NodeKey, _, err = crypto.GenerateEd25519Key(rand.Reader)
+		default:
+			return nil, fmt.Errorf("P2P protocol error, unknown signing scheme: %s", c.Scheme)
 		}
-		c.NodeKey, _, err = crypto.GenerateEd25519Key(seed)
 		if err != nil {
 			return nil, fmt.Errorf("P2P protocol error, unable to generate a random private key: %w", err)
 		}
@@ -77,6 +124,11 @@ func New(c Config) (*Protocol, error) {
 		return nil, fmt.Errorf("P2P protocol error, unable to parse listenAddrs: %w", err)
 	}
 
+	bootstrapAddrs, err := convertAddress(c.BootstrapAddress)
+	if err != nil {
+		return nil, fmt.Errorf("P2P protocol error, unable to parse bootstrap addresses: %w", err)
+	}
+
 	mgr, _ := connmgr.NewConnManager(minConnections, maxConnections, connmgr.WithGracePeriod(5*time.Minute))
 
 	op := []libp2p.Option{
@@ -85,59 +137,111 @@ func New(c Config) (*Protocol, error) {
 		libp2p.ConnectionManager(mgr),
 		libp2p.Identity(c.NodeKey),
 	}
+	if len(c.PSK) > 0 {
+		op = append(op, libp2p.PrivateNetwork(c.PSK))
+	}
+	if !c.Gater.Empty() {
+		gater, err := NewGater(c.Gater)
+		if err != nil {
+			return nil, fmt.Errorf("P2P protocol error, unable to create connection gater: %w", err)
+		}
+		op = append(op, libp2p.ConnectionGater(gater))
+	}
 
 	n, err := NewNode(NodeConfig{
-		Options: op,
-		NodeKey: c.NodeKey,
-		Title:   c.Titles,
+		Options:   op,
+		NodeKey:   c.NodeKey,
+		PeerScore: c.PeerScore,
+		Discovery: DiscoveryConfig{
+			Bootstrap:  bootstrapAddrs,
+			Rendezvous: c.Rendezvous,
+			MDNS:       c.MDNS,
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Protocol error, unable to initialize node: %w", err)
 	}
 
+	// Built-in validators run before the ones added with AddValidator. The
+	// schema validator runs first, because the others use the message it
+	// unmarshals.
+	_ = n.AddValidator(ValidatorSchema, "", SchemaValidator())
+	if c.Signers != nil {
+		_ = n.AddValidator(ValidatorAllowlist, "", AllowlistValidator(c.Signers))
+	}
+	_ = n.AddValidator(ValidatorFreshness, "", FreshnessValidator(c.MaxMessageAge, c.MaxClockSkew))
+	_ = n.AddValidator(ValidatorPrice, "", PriceValidator(c.PriceBounds))
+	_ = n.AddValidator(ValidatorSignature, "", SignatureValidator(c.Signers))
+
 	id, err := peer.IDFromPrivateKey(c.NodeKey)
 	if err != nil {
 		return nil, fmt.Errorf("P2P transport error, unable to get public ID from private key: %w", err)
 	}
 
+	if c.Signer == nil {
+		if c.Signer, err = NewLocalSigner(c.NodeKey); err != nil {
+			return nil, fmt.Errorf("P2P protocol error, unable to create signer: %w", err)
+		}
+	}
+
 	return &Protocol{
 		id:          id,
 		node:        n,
+		signer:      c.Signer,
 		isBootstrap: c.IsBootstrap,
-		bootAddress: c.BootstrapAddress,
 		titles:      c.Titles,
 		msgCh:       make(chan ReceivedMessage),
 	}, nil
 }
 
+// AddValidator adds a validator of the topic, or of all topics if topic is
+// empty, which runs after the built-in validators. Validators must be added
+// before the protocol is started.
+func (p *Protocol) AddValidator(name string, topic string, validator Validator) error {
+	if err := p.node.AddValidator(name, topic, validator); err != nil {
+		return fmt.Errorf("Protocol error, unable to add validator %s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate runs the validator pipeline of the topic on a message which is
+// not received by gossip, as if it was published by the author, so it's
+// checked like the gossip messages.
+func (p *Protocol) Validate(ctx context.Context, title string, author peer.ID, msg *ProtocolMessage) error {
+	return p.node.validatorSet.Validate(ctx, title, author, msg)
+}
+
+// PeerScores returns the GossipSub scores of the connected peers, or nil if
+// peer scoring is disabled.
+func (p *Protocol) PeerScores() []PeerScore {
+	return p.node.PeerScores()
+}
+
 // Start implements the transport.Transport interface.
 func (p *Protocol) Start(ctx context.Context) error {
 	if err := p.node.Start(ctx); err != nil {
 		return fmt.Errorf("Protocol error, unable to start node: %w", err)
 	}
 	if p.isBootstrap == false {
-
-		if err := p.subscribe(p.titles); err != nil {
-			return err
+		for _, title := range p.titles {
+			if err := p.subscribe(title); err != nil {
+				return err
+			}
 		}
-
-	}
-	if err := p.bootstrap(p.bootAddress); err != nil {
-		return fmt.Errorf("Protocol error, unable to start node: %w", err)
 	}
 	return nil
 }
 
 // Broadcast implements the transport.Transport interface.
-func (p *Protocol) Broadcast(message UnsignedMessage) (SignedMessage, error) {
-	if len(p.titles) == 0 {
+func (p *Protocol) Broadcast(title string, message UnsignedMessage) (SignedMessage, error) {
+	if len(title) == 0 {
 		return nil, fmt.Errorf("%w", global.ErrEmptyTitle)
 	}
-	sub, err := p.node.Subscription(p.titles)
+	sub, err := p.node.Subscription(title)
 	if err != nil {
-		return nil, fmt.Errorf("Protocol error, unable to get subscription for %s topic: %w", p.titles, err)
+		return nil, fmt.Errorf("Protocol error, unable to get subscription for %s topic: %w", title, err)
 	}
-	sign, err := message.Sign(p.node.peerStore.PrivKey(p.id))
+	sign, err := message.Sign(p.node.ctx, p.Signer())
 	if err != nil {
 		return nil, fmt.Errorf("Protocol error, failed to sign: %w", err)
 	}
@@ -146,6 +250,9 @@ func (p *Protocol) Broadcast(message UnsignedMessage) (SignedMessage, error) {
 		return nil, fmt.Errorf("Protocol error, unable to marshall message: %w", err)
 	}
 	err = sub.Publish(data)
+	if err == nil {
+		metrics.MessagesPublished.WithLabelValues(title).Inc()
+	}
 	return sign, err
 }
 
@@ -159,12 +266,6 @@ func (p *Protocol) subscribe(title string) error {
 	return nil
 }
 
-// Discovery the bootstrap node for connecting
-func (p *Protocol) bootstrap(addrs []string) error {
-	bootstraps, _ := convertAddress(addrs)
-	return p.node.Discovery(bootstraps)
-}
-
 func (p *Protocol) messagesLoop(title string, sub *Subscription) {
 	for {
 		select {
@@ -180,6 +281,7 @@ func (p *Protocol) messagesLoop(title string, sub *Subscription) {
 			if !ok {
 				continue
 			}
+			metrics.MessagesReceived.WithLabelValues(title).Inc()
 			msg := ReceivedMessage{
 				From:    id.String(),
 				Topic:   title,
@@ -192,6 +294,35 @@ func (p *Protocol) messagesLoop(title string, sub *Subscription) {
 	}
 }
 
+// Host returns the libp2p host of the node. It's nil until the protocol is
+// started.
+func (p *Protocol) Host() host.Host {
+	return p.node.Host()
+}
+
+// Address returns the signer address of the node.
+func (p *Protocol) Address() common.Address {
+	return p.Signer().Address()
+}
+
+// Signer returns the current signer of the node.
+func (p *Protocol) Signer() Signer {
+	p.signerMu.RLock()
+	defer p.signerMu.RUnlock()
+
+	return p.signer
+}
+
+// SetSigner replaces the signer of the node, e.g. after a key rotation. It
+// can be called while the protocol is running, the next broadcast messages
+// are signed by the new signer. The peer ID of the node is not changed.
+func (p *Protocol) SetSigner(signer Signer) {
+	p.signerMu.Lock()
+	defer p.signerMu.Unlock()
+
+	p.signer = signer
+}
+
 func (p *Protocol) Message() <-chan ReceivedMessage {
 	return p.msgCh
 }
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"sort"
+	"time"
+)
+
+// scoreInspectInterval is how often the peer scores are copied for PeerScores.
+const scoreInspectInterval = 10 * time.Second
+
+// ScoreConfig is the configuration of the GossipSub peer scoring. Peers
+// with a negative score are not gossiped to, and peers below the graylist
+// threshold are ignored completely.
+type ScoreConfig struct {
+	// Topics maps the scored topics to the interval of their rounds. Each
+	// node publishes one message per round, so the decays of the delivery
+	// counters are derived from it.
+	Topics map[string]time.Duration
+	// IPColocationThreshold is the number of peers which may share an IP
+	// address before all of them are penalized.
+	IPColocationThreshold int
+	// MeshDeliveriesThreshold is the number of messages a mesh peer is
+	// expected to deliver per round. Peers which deliver less are penalized
+	// and eventually pruned from the mesh.
+	MeshDeliveriesThreshold float64
+
+	// GossipThreshold is the score below which no gossip is exchanged with
+	// the peer.
+	GossipThreshold float64
+	// PublishThreshold is the score below which own messages are not
+	// published to the peer.
+	PublishThreshold float64
+	// GraylistThreshold is the score below which all messages of the peer
+	// are ignored.
+	GraylistThreshold float64
+	// AcceptPXThreshold is the score a peer needs for its peer exchange to
+	// be accepted on prune.
+	AcceptPXThreshold float64
+	// OpportunisticGraftThreshold is the median score of the mesh below
+	// which better scoring peers are grafted.
+	OpportunisticGraftThreshold float64
+}
+
+// PeerScore is the score of a peer with its components.
+type PeerScore struct {
+	Peer               peer.ID                     `json:"peer"`
+	Score              float64                     `json:"score"`
+	Graylisted         bool                        `json:"graylisted"`
+	AppSpecificScore   float64                     `json:"app_specific_score"`
+	IPColocationFactor float64                     `json:"ip_colocation_factor"`
+	BehaviourPenalty   float64                     `json:"behaviour_penalty"`
+	Topics             map[string]TopicScoreDetail `json:"topics"`
+}
+
+// TopicScoreDetail is the score counters of a peer in a topic.
+type TopicScoreDetail struct {
+	TimeInMesh               time.Duration `json:"time_in_mesh"`
+	FirstMessageDeliveries   float64       `json:"first_message_deliveries"`
+	MeshMessageDeliveries    float64       `json:"mesh_message_deliveries"`
+	InvalidMessageDeliveries float64       `json:"invalid_message_deliveries"`
+}
+
+// params returns the GossipSub score parameters and thresholds of the
+// configuration.
+func (c ScoreConfig) params() (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds) {
+	params := &pubsub.PeerScoreParams{
+		Topics:        make(map[string]*pubsub.TopicScoreParams, len(c.Topics)),
+		TopicScoreCap: 100,
+		AppSpecificScore: func(peer.ID) float64 {
+			return 0
+		},
+		AppSpecificWeight:           1,
+		IPColocationFactorWeight:    -50,
+		IPColocationFactorThreshold: c.IPColocationThreshold,
+		BehaviourPenaltyWeight:      -10,
+		BehaviourPenaltyThreshold:   6,
+		BehaviourPenaltyDecay:       pubsub.ScoreParameterDecay(10 * time.Minute),
+		DecayInterval:               pubsub.DefaultDecayInterval,
+		DecayToZero:                 pubsub.DefaultDecayToZero,
+		RetainScore:                 time.Hour,
+	}
+	for topic, interval := range c.Topics {
+		params.Topics[topic] = &pubsub.TopicScoreParams{
+			TopicWeight: 1,
+			// A peer gains up to 10 points by staying in the mesh for an hour
+			TimeInMeshWeight:  0.01,
+			TimeInMeshQuantum: 10 * time.Second,
+			TimeInMeshCap:     360,
+			// First deliveries are remembered for 10 rounds
+			FirstMessageDeliveriesWeight: 1,
+			FirstMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(10 * interval),
+			FirstMessageDeliveriesCap:    50,
+			// Mesh deliveries are checked after 5 rounds in the mesh, and
+			// remembered for 5 rounds
+			MeshMessageDeliveriesWeight:     -1,
+			MeshMessageDeliveriesDecay:      pubsub.ScoreParameterDecay(5 * interval),
+			MeshMessageDeliveriesThreshold:  c.MeshDeliveriesThreshold,
+			MeshMessageDeliveriesCap:        10 * c.MeshDeliveriesThreshold,
+			MeshMessageDeliveriesActivation: 5 * interval,
+			MeshMessageDeliveriesWindow:     100 * time.Millisecond,
+			MeshFailurePenaltyWeight:        -1,
+			MeshFailurePenaltyDecay:         pubsub.ScoreParameterDecay(5 * interval),
+			// A single invalid message costs 100 points, which is forgotten
+			// after an hour
+			InvalidMessageDeliveriesWeight: -100,
+			InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+		}
+	}
+	thresholds := &pubsub.PeerScoreThresholds{
+		GossipThreshold:             c.GossipThreshold,
+		PublishThreshold:            c.PublishThreshold,
+		GraylistThreshold:           c.GraylistThreshold,
+		AcceptPXThreshold:           c.AcceptPXThreshold,
+		OpportunisticGraftThreshold: c.OpportunisticGraftThreshold,
+	}
+	return params, thresholds
+}
+
+// Validate returns an error if the configuration is not valid. The other
+// parameters are validated by GossipSub when the node is started.
+func (c ScoreConfig) Validate() error {
+	switch {
+	case c.IPColocationThreshold < 1:
+		return fmt.Errorf("peer score error, IP colocation threshold must be at least 1: %d", c.IPColocationThreshold)
+	case c.MeshDeliveriesThreshold <= 0:
+		return fmt.Errorf("peer score error, mesh deliveries threshold must be positive: %v", c.MeshDeliveriesThreshold)
+	case c.GossipThreshold > 0:
+		return fmt.Errorf("peer score error, gossip threshold must not be positive: %v", c.GossipThreshold)
+	case c.PublishThreshold > c.GossipThreshold:
+		return fmt.Errorf("peer score error, publish threshold must not be greater than gossip threshold: %v", c.PublishThreshold)
+	case c.GraylistThreshold > c.PublishThreshold:
+		return fmt.Errorf("peer score error, graylist threshold must not be greater than publish threshold: %v", c.GraylistThreshold)
+	case c.AcceptPXThreshold < 0 || c.OpportunisticGraftThreshold < 0:
+		return fmt.Errorf("peer score error, accept PX and opportunistic graft thresholds must not be negative")
+	}
+	for topic, interval := range c.Topics {
+		if interval < time.Second {
+			return fmt.Errorf("peer score error, round interval of %s must be at least 1s: %v", topic, interval)
+		}
+	}
+	return nil
+}
+
+// inspectScores stores the peer scores reported by GossipSub.
+func (n *Node) inspectScores(scores map[peer.ID]*pubsub.PeerScoreSnapshot) {
+	n.scoresMu.Lock()
+	defer n.scoresMu.Unlock()
+
+	n.scores = scores
+}
+
+// PeerScores returns the last scores of the connected peers, sorted by
+// score. It returns nil if peer scoring is disabled.
+func (n *Node) PeerScores() []PeerScore {
+	if n.peerScore == nil {
+		return nil
+	}
+	n.scoresMu.Lock()
+	defer n.scoresMu.Unlock()
+
+	res := make([]PeerScore, 0, len(n.scores))
+	for id, s := range n.scores {
+		ps := PeerScore{
+			Peer:               id,
+			Score:              s.Score,
+			Graylisted:         s.Score < n.peerScore.GraylistThreshold,
+			AppSpecificScore:   s.AppSpecificScore,
+			IPColocationFactor: s.IPColocationFactor,
+			BehaviourPenalty:   s.BehaviourPenalty,
+			Topics:             make(map[string]TopicScoreDetail, len(s.Topics)),
+		}
+		for topic, t := range s.Topics {
+			ps.Topics[topic] = TopicScoreDetail{
+				TimeInMesh:               t.TimeInMesh,
+				FirstMessageDeliveries:   t.FirstMessageDeliveries,
+				MeshMessageDeliveries:    t.MeshMessageDeliveries,
+				InvalidMessageDeliveries: t.InvalidMessageDeliveries,
+			}
+		}
+		res = append(res, ps)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Score < res[j].Score
+	})
+	return res
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+)
+
+// Signer signs the messages broadcast by the node. The key is either held
+// by the node, with LocalSigner, or by a separate signing daemon, so the
+// node never sees the raw key material.
+type Signer interface {
+	// Address returns the signer address of the key.
+	Address() common.Address
+	// Scheme returns the signing scheme of the key.
+	Scheme() string
+	// Sign returns the signature of the message. The version, the scheme,
+	// the signer and the signed time of the message are already set.
+	Sign(ctx context.Context, msg *ProtocolMessage) (Signature, error)
+}
+
+// LocalSigner signs the messages with a key held in the process. Secp256k1
+// keys produce EIP-712 signatures, other keys sign the Digest of the
+// message.
+type LocalSigner struct {
+	key     crypto.PrivKey
+	address common.Address
+}
+
+// NewLocalSigner returns a new signer of the key.
+func NewLocalSigner(key crypto.PrivKey) (*LocalSigner, error) {
+	pid, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("signer error, invalid key: %w", err)
+	}
+	return &LocalSigner{key: key, address: global.PeerIDToAddress(pid)}, nil
+}
+
+// Address implements the Signer interface.
+func (s *LocalSigner) Address() common.Address {
+	return s.address
+}
+
+// Scheme implements the Signer interface.
+func (s *LocalSigner) Scheme() string {
+	if s.key.Type() == crypto.Secp256k1 {
+		return SchemeEIP712
+	}
+	return SchemeEd25519
+}
+
+// PublicKey returns the public key of the signer.
+func (s *LocalSigner) PublicKey() crypto.PubKey {
+	return s.key.GetPublic()
+}
+
+// Sign implements the Signer interface.
+func (s *LocalSigner) Sign(_ context.Context, msg *ProtocolMessage) (Signature, error) {
+	if msg.Signer != s.address || msg.Scheme != s.Scheme() {
+		return nil, fmt.Errorf("signer error, message of %s (%s) cannot be signed by %s (%s)", msg.Signer, msg.Scheme, s.address, s.Scheme())
+	}
+	if msg.Scheme == SchemeEIP712 {
+		return signEIP712(msg, s.key)
+	}
+	digest, err := Digest(msg)
+	if err != nil {
+		return nil, err
+	}
+	return s.key.Sign(digest)
+}
@@ -12,18 +12,16 @@ type Subscription struct {
 	title        *pubsub.Topic
 	subscription *pubsub.Subscription
 	cancelRelay  pubsub.RelayCancelFunc
-	validatorSet *ValidatorSet
 	msgCh        chan *pubsub.Message
 }
 
-func newSubscription(node *Node, title string, validator pubsub.Validator) (*Subscription, error) {
+func newSubscription(node *Node, title string, validator pubsub.ValidatorEx) (*Subscription, error) {
 	var err error
 	ctx, ctxCancel := context.WithCancel(node.ctx)
 	s := &Subscription{
-		ctx:          ctx,
-		ctxCancel:    ctxCancel,
-		validatorSet: node.validatorSet,
-		msgCh:        make(chan *pubsub.Message),
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		msgCh:     make(chan *pubsub.Message),
 	}
 	err = node.pubSub.RegisterTopicValidator(title, validator)
 	if err != nil {
@@ -68,13 +66,6 @@ func (s *Subscription) messageLoop() {
 	}
 }
 
-//func (s *Subscription) validator(title string) pubsub.ValidatorEx {
-//	return func(ctx context.Context, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
-//		vr := s.validatorSet.Validator(title)(ctx, id, psMsg)
-//		return vr
-//	}
-//}
-
 func (s *Subscription) close() error {
 	s.ctxCancel()
 	s.subscription.Cancel()
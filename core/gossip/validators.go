@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"context"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	"gossip-price/core/registry"
+	"log"
+	"math"
+	"time"
+)
+
+// Names of the built-in validators, used as the reason of the rejected and
+// ignored message metrics.
+const (
+	ValidatorSchema    = "schema"
+	ValidatorAllowlist = "allowlist"
+	ValidatorFreshness = "freshness"
+	ValidatorPrice     = "price_bounds"
+	ValidatorSignature = "signature"
+)
+
+// PriceBounds are the minimum and maximum accepted prices of a pair. Zero
+// disables the bound.
+type PriceBounds struct {
+	Min float64
+	Max float64
+}
+
+// MessageOf returns the message unmarshalled by the schema validator, or
+// nil if the message was not validated yet.
+func MessageOf(psMsg *pubsub.Message) *ProtocolMessage {
+	msg, _ := psMsg.ValidatorData.(*ProtocolMessage)
+	return msg
+}
+
+// SchemaValidator rejects messages which cannot be unmarshalled, or which
+// lack the id, the pair or the signature. The unmarshalled message is
+// stored in the ValidatorData field, which is used by the next validators
+// and when the message is received.
+func SchemaValidator() Validator {
+	return func(ctx context.Context, topic string, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
+		msg := &ProtocolMessage{}
+		if err := msg.UnmarshalJSON(psMsg.Data); err != nil {
+			return pubsub.ValidationReject
+		}
+		if msg.Version != SigningVersion || msg.MsgId == "" || msg.Pair == "" || len(msg.Signature) == 0 || msg.SignedTime.IsZero() {
+			return pubsub.ValidationReject
+		}
+		psMsg.ValidatorData = msg
+		return pubsub.ValidationAccept
+	}
+}
+
+// AllowlistValidator rejects messages of signers which are not in the
+// registry, or whose key is not valid at the signed time of the message.
+func AllowlistValidator(signers *registry.Registry) Validator {
+	return func(ctx context.Context, topic string, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
+		msg := MessageOf(psMsg)
+		if msg == nil {
+			return pubsub.ValidationReject
+		}
+		if !signers.IsAuthorizedAt(msg.Signer, msg.SignedTime) {
+			log.Printf("Rejected message(%s) from %s: %v %s", msg.MsgId, psMsg.GetFrom(), global.ErrUnknownSigner, msg.Signer)
+			return pubsub.ValidationReject
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// FreshnessValidator ignores messages signed more than maxAge ago, which
+// may be delayed by the network, and rejects messages signed more than
+// maxSkew in the future.
+func FreshnessValidator(maxAge, maxSkew time.Duration) Validator {
+	return func(ctx context.Context, topic string, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
+		msg := MessageOf(psMsg)
+		if msg == nil {
+			return pubsub.ValidationReject
+		}
+		now := time.Now()
+		if msg.SignedTime.After(now.Add(maxSkew)) {
+			return pubsub.ValidationReject
+		}
+		if maxAge > 0 && now.Sub(msg.SignedTime) > maxAge {
+			return pubsub.ValidationIgnore
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// PriceValidator rejects messages with a price which is not a positive
+// finite number, or which is out of the bounds of its pair.
+func PriceValidator(bounds map[string]PriceBounds) Validator {
+	return func(ctx context.Context, topic string, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
+		msg := MessageOf(psMsg)
+		if msg == nil {
+			return pubsub.ValidationReject
+		}
+		if math.IsNaN(msg.Price) || math.IsInf(msg.Price, 0) || msg.Price <= 0 {
+			return pubsub.ValidationReject
+		}
+		b, ok := bounds[msg.Pair]
+		if !ok {
+			return pubsub.ValidationAccept
+		}
+		if (b.Min > 0 && msg.Price < b.Min) || (b.Max > 0 && msg.Price > b.Max) {
+			log.Printf("Rejected message(%s) from %s: price %v out of bounds of %s", msg.MsgId, psMsg.GetFrom(), msg.Price, msg.Pair)
+			return pubsub.ValidationReject
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// SignatureValidator rejects messages which are not signed by their signer.
+// Ed25519 messages are verified with the key of their author, or with the
+// key of their signer in the registry if the signer is not the author,
+// e.g. when the node signs with a remote signer.
+func SignatureValidator(signers *registry.Registry) Validator {
+	return func(ctx context.Context, topic string, id peer.ID, psMsg *pubsub.Message) pubsub.ValidationResult {
+		msg := MessageOf(psMsg)
+		if msg == nil {
+			return pubsub.ValidationReject
+		}
+		// The author key is only required by the schemes which cannot
+		// recover the signer from the signature
+		pub, _ := authorKey(psMsg)
+		if signers != nil && msg.Scheme == SchemeEd25519 && global.PeerIDToAddress(psMsg.GetFrom()) != msg.Signer {
+			if s, ok := signers.SignerAt(msg.Signer, msg.SignedTime); ok && s.PublicKey != nil {
+				pub = s.PublicKey
+			}
+		}
+		if err := Verify(msg, pub); err != nil {
+			log.Printf("Rejected message(%s) from %s: %v", msg.MsgId, psMsg.GetFrom(), err)
+			return pubsub.ValidationReject
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// authorKey returns the public key of the author of the pubsub message.
+func authorKey(psMsg *pubsub.Message) (crypto.PubKey, error) {
+	if len(psMsg.Key) > 0 {
+		return crypto.UnmarshalPublicKey(psMsg.Key)
+	}
+	return psMsg.GetFrom().ExtractPublicKey()
+}
@@ -0,0 +1,291 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	"gossip-price/core/registry"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// builtinValidators returns the validator set of the built-in validators
+// without signer registry, as set up by NewProtocol.
+func builtinValidators(bounds map[string]PriceBounds) *ValidatorSet {
+	set := &ValidatorSet{}
+	set.Add(ValidatorSchema, "", SchemaValidator())
+	set.Add(ValidatorFreshness, "", FreshnessValidator(5*time.Minute, 30*time.Second))
+	set.Add(ValidatorPrice, "", PriceValidator(bounds))
+	set.Add(ValidatorSignature, "", SignatureValidator(nil))
+	return set
+}
+
+func TestValidatorSetValidate(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	author, err := peer.IDFromPublicKey(signer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := peer.IDFromPublicKey(newTestSigner(t, crypto.Ed25519).PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := builtinValidators(map[string]PriceBounds{"ETH/USD": {Min: 1000, Max: 3000}})
+
+	tests := []struct {
+		name   string
+		price  float64
+		signed time.Duration
+		modify func(msg *ProtocolMessage)
+		author peer.ID
+		valid  bool
+	}{
+		{"valid", 2000, 0, nil, author, true},
+		{"out of bounds", 3500, 0, nil, author, false},
+		{"below bounds", 500, 0, nil, author, false},
+		{"stale", 2000, -10 * time.Minute, nil, author, false},
+		{"future", 2000, time.Minute, nil, author, false},
+		{"tampered", 2000, 0, func(msg *ProtocolMessage) { msg.Price = 2001 }, author, false},
+		{"without id", 2000, 0, func(msg *ProtocolMessage) { msg.MsgId = "" }, author, false},
+		{"other author", 2000, 0, nil, other, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Sign sets the signed time to now, so the message is signed
+			// directly by the signer
+			msg := &ProtocolMessage{
+				Version:    SigningVersion,
+				Scheme:     signer.Scheme(),
+				MsgId:      "ETH/USD-1",
+				Pair:       "ETH/USD",
+				Price:      tt.price,
+				Signer:     signer.Address(),
+				SignedTime: time.Unix(time.Now().Add(tt.signed).Unix(), 0),
+			}
+			sig, err := signer.Sign(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Sign() error: %v", err)
+			}
+			msg.Signature = sig
+			if tt.modify != nil {
+				tt.modify(msg)
+			}
+			err = set.Validate(context.Background(), "price/ETH-USD", tt.author, msg)
+			if tt.valid && err != nil {
+				t.Errorf("Validate() error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, global.ErrInvalidMessage) {
+				t.Errorf("Validate() error = %v, want %v", err, global.ErrInvalidMessage)
+			}
+		})
+	}
+}
+
+func TestValidatorSetTopics(t *testing.T) {
+	signer := newTestSigner(t, crypto.Secp256k1)
+	msg := signedMessage(t, signer, "ETH/USD", 2000)
+	set := builtinValidators(nil)
+	var validated []string
+	set.Add("custom", "price/BTC-USD", func(_ context.Context, topic string, _ peer.ID, _ *pubsub.Message) pubsub.ValidationResult {
+		validated = append(validated, topic)
+		return pubsub.ValidationReject
+	})
+
+	// Validators of other topics do not run
+	author, _ := peer.IDFromPublicKey(signer.PublicKey())
+	if err := set.Validate(context.Background(), "price/ETH-USD", author, msg); err != nil {
+		t.Errorf("Validate() of another topic error: %v", err)
+	}
+	if err := set.Validate(context.Background(), "price/BTC-USD", author, msg); err == nil {
+		t.Error("Validate() error = nil, want the custom validator to reject the message")
+	}
+	if len(validated) != 1 || validated[0] != "price/BTC-USD" {
+		t.Errorf("custom validator ran for %v, want price/BTC-USD", validated)
+	}
+}
+
+// pubsubMessage returns the pubsub message of the message published by the
+// author, unmarshalled by the schema validator.
+func pubsubMessage(t *testing.T, msg *ProtocolMessage, author peer.ID) *pubsub.Message {
+	t.Helper()
+	data, err := msg.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	psMsg := &pubsub.Message{Message: &pb.Message{Data: data, From: []byte(author)}, ReceivedFrom: author}
+	if res := SchemaValidator()(context.Background(), "price/ETH-USD", author, psMsg); res != pubsub.ValidationAccept {
+		t.Fatalf("SchemaValidator() = %v, want accept", res)
+	}
+	return psMsg
+}
+
+func TestSchemaValidator(t *testing.T) {
+	signer := newTestSigner(t, crypto.Secp256k1)
+	tests := []struct {
+		name   string
+		modify func(msg *ProtocolMessage)
+		want   pubsub.ValidationResult
+	}{
+		{"valid", func(*ProtocolMessage) {}, pubsub.ValidationAccept},
+		{"version", func(msg *ProtocolMessage) { msg.Version = 2 }, pubsub.ValidationReject},
+		{"without id", func(msg *ProtocolMessage) { msg.MsgId = "" }, pubsub.ValidationReject},
+		{"without pair", func(msg *ProtocolMessage) { msg.Pair = "" }, pubsub.ValidationReject},
+		{"without signature", func(msg *ProtocolMessage) { msg.Signature = nil }, pubsub.ValidationReject},
+		{"without signed time", func(msg *ProtocolMessage) { msg.SignedTime = time.Time{} }, pubsub.ValidationReject},
+	}
+	for _, tt := range tests {
+		msg := signedMessage(t, signer, "ETH/USD", 2000)
+		tt.modify(msg)
+		data, err := msg.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		psMsg := &pubsub.Message{Message: &pb.Message{Data: data}}
+		if got := SchemaValidator()(context.Background(), "price/ETH-USD", "", psMsg); got != tt.want {
+			t.Errorf("%s: SchemaValidator() = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.want == pubsub.ValidationAccept && MessageOf(psMsg) == nil {
+			t.Errorf("%s: MessageOf() = nil, want the unmarshalled message", tt.name)
+		}
+	}
+
+	psMsg := &pubsub.Message{Message: &pb.Message{Data: []byte("{")}}
+	if got := SchemaValidator()(context.Background(), "price/ETH-USD", "", psMsg); got != pubsub.ValidationReject {
+		t.Errorf("SchemaValidator() of invalid JSON = %v, want reject", got)
+	}
+}
+
+func TestFreshnessAndPriceValidators(t *testing.T) {
+	now := time.Now()
+	freshness := FreshnessValidator(5*time.Minute, 30*time.Second)
+	bounds := PriceValidator(map[string]PriceBounds{"ETH/USD": {Min: 1000, Max: 3000}, "BTC/USD": {Max: 100000}})
+
+	tests := []struct {
+		name    string
+		pair    string
+		price   float64
+		signed  time.Time
+		fresh   pubsub.ValidationResult
+		bounded pubsub.ValidationResult
+	}{
+		{"valid", "ETH/USD", 2000, now, pubsub.ValidationAccept, pubsub.ValidationAccept},
+		// Delayed messages are ignored, not rejected, as the author may be honest
+		{"stale", "ETH/USD", 2000, now.Add(-6 * time.Minute), pubsub.ValidationIgnore, pubsub.ValidationAccept},
+		{"skewed", "ETH/USD", 2000, now.Add(20 * time.Second), pubsub.ValidationAccept, pubsub.ValidationAccept},
+		{"future", "ETH/USD", 2000, now.Add(time.Minute), pubsub.ValidationReject, pubsub.ValidationAccept},
+		{"above bounds", "ETH/USD", 3000.01, now, pubsub.ValidationAccept, pubsub.ValidationReject},
+		{"below bounds", "ETH/USD", 999, now, pubsub.ValidationAccept, pubsub.ValidationReject},
+		{"without min", "BTC/USD", 1, now, pubsub.ValidationAccept, pubsub.ValidationAccept},
+		{"without bounds", "SOL/USD", 1e9, now, pubsub.ValidationAccept, pubsub.ValidationAccept},
+		{"zero", "SOL/USD", 0, now, pubsub.ValidationAccept, pubsub.ValidationReject},
+		{"negative", "SOL/USD", -1, now, pubsub.ValidationAccept, pubsub.ValidationReject},
+		{"infinite", "SOL/USD", math.Inf(1), now, pubsub.ValidationAccept, pubsub.ValidationReject},
+	}
+	for _, tt := range tests {
+		// The validators only read the unmarshalled message
+		psMsg := &pubsub.Message{
+			Message:       &pb.Message{},
+			ValidatorData: &ProtocolMessage{MsgId: "1", Pair: tt.pair, Price: tt.price, SignedTime: tt.signed},
+		}
+		if got := freshness(context.Background(), "", "", psMsg); got != tt.fresh {
+			t.Errorf("%s: FreshnessValidator() = %v, want %v", tt.name, got, tt.fresh)
+		}
+		if got := bounds(context.Background(), "", "", psMsg); got != tt.bounded {
+			t.Errorf("%s: PriceValidator() = %v, want %v", tt.name, got, tt.bounded)
+		}
+	}
+}
+
+// signerSource is a registry source of the signers.
+type signerSource []registry.Signer
+
+func (s signerSource) Name() string { return "test" }
+
+func (s signerSource) Load(context.Context) ([]registry.Signer, error) { return s, nil }
+
+func TestAllowlistAndSignatureValidators(t *testing.T) {
+	node := newTestSigner(t, crypto.Ed25519)
+	remote := newTestSigner(t, crypto.Ed25519)
+	expired := newTestSigner(t, crypto.Secp256k1)
+	unknown := newTestSigner(t, crypto.Secp256k1)
+	signers := registry.New(signerSource{
+		{Address: node.Address(), PublicKey: node.PublicKey()},
+		{Address: remote.Address(), PublicKey: remote.PublicKey()},
+		{Address: expired.Address(), ExpiresAt: time.Now().Add(-time.Hour)},
+	})
+	if err := signers.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	author, err := peer.IDFromPublicKey(node.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		signer    *LocalSigner
+		registry  *registry.Registry
+		allowlist pubsub.ValidationResult
+		signature pubsub.ValidationResult
+	}{
+		{"node key", node, signers, pubsub.ValidationAccept, pubsub.ValidationAccept},
+		// The node signs with a remote signer, whose key is in the registry
+		{"remote signer", remote, signers, pubsub.ValidationAccept, pubsub.ValidationAccept},
+		{"remote signer without registry", remote, nil, pubsub.ValidationAccept, pubsub.ValidationReject},
+		{"expired key", expired, signers, pubsub.ValidationReject, pubsub.ValidationAccept},
+		{"unknown signer", unknown, signers, pubsub.ValidationReject, pubsub.ValidationAccept},
+	}
+	for _, tt := range tests {
+		psMsg := pubsubMessage(t, signedMessage(t, tt.signer, "ETH/USD", 2000), author)
+		if tt.registry != nil {
+			if got := AllowlistValidator(tt.registry)(context.Background(), "", author, psMsg); got != tt.allowlist {
+				t.Errorf("%s: AllowlistValidator() = %v, want %v", tt.name, got, tt.allowlist)
+			}
+		}
+		if got := SignatureValidator(tt.registry)(context.Background(), "", author, psMsg); got != tt.signature {
+			t.Errorf("%s: SignatureValidator() = %v, want %v", tt.name, got, tt.signature)
+		}
+	}
+}
+
+func TestValidatorSetStopsAtFirstResult(t *testing.T) {
+	var ran []string
+	validator := func(name string, res pubsub.ValidationResult) Validator {
+		return func(context.Context, string, peer.ID, *pubsub.Message) pubsub.ValidationResult {
+			ran = append(ran, name)
+			return res
+		}
+	}
+	tests := []struct {
+		results []pubsub.ValidationResult
+		want    pubsub.ValidationResult
+		ran     int
+	}{
+		{[]pubsub.ValidationResult{pubsub.ValidationAccept, pubsub.ValidationAccept}, pubsub.ValidationAccept, 2},
+		{[]pubsub.ValidationResult{pubsub.ValidationIgnore, pubsub.ValidationReject}, pubsub.ValidationIgnore, 1},
+		{[]pubsub.ValidationResult{pubsub.ValidationAccept, pubsub.ValidationReject, pubsub.ValidationAccept}, pubsub.ValidationReject, 2},
+	}
+	for _, tt := range tests {
+		ran = nil
+		set := &ValidatorSet{}
+		for i, res := range tt.results {
+			set.Add(strconv.Itoa(i), "", validator(strconv.Itoa(i), res))
+		}
+		psMsg := &pubsub.Message{Message: &pb.Message{}}
+		if got := set.Validator("price/ETH-USD")(context.Background(), "", psMsg); got != tt.want || len(ran) != tt.ran {
+			t.Errorf("Validator() of %v = %v after %v, want %v after %d validators", tt.results, got, ran, tt.want, tt.ran)
+		}
+		for i, name := range ran {
+			if name != strconv.Itoa(i) {
+				t.Errorf("validators ran in the order %v", ran)
+				break
+			}
+		}
+	}
+}
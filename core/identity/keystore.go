@@ -0,0 +1,179 @@
+// Package identity loads and stores the persistent key of a node. The key
+// is the libp2p identity of the node, and its signer address is derived
+// from it, so it must survive restarts.
+//
+// Secp256k1 keys are stored in the JSON keystore format of geth, so they can
+// also be imported to Ethereum wallets. Ed25519 keys, which are not
+// supported by geth, are stored in the same format with the marshalled
+// libp2p key as the encrypted data:
+//
+//	{"type": "libp2p", "peer_id": "12D3KooW...", "crypto": {...}, "version": 3}
+package identity
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	protocol "gossip-price/core/gossip"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyTypeLibp2p is the type of the keystore files of Ed25519 keys.
+const keyTypeLibp2p = "libp2p"
+
+// version is the version of the keystore format.
+const version = 3
+
+// scryptN and scryptP are the scrypt parameters of the encrypted keys.
+var scryptN, scryptP = keystore.StandardScryptN, keystore.StandardScryptP
+
+// libp2pKeyJSON is the keystore file of Ed25519 keys.
+type libp2pKeyJSON struct {
+	Type    string              `json:"type"`
+	PeerID  string              `json:"peer_id"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+	Id      string              `json:"id"`
+	Version int                 `json:"version"`
+}
+
+// Generate returns a new random key of the signing scheme. SchemeEIP712
+// uses a secp256k1 key, SchemeEd25519 an Ed25519 key.
+func Generate(scheme string) (crypto.PrivKey, error) {
+	var (
+		key crypto.PrivKey
+		err error
+	)
+	switch scheme {
+	case protocol.SchemeEIP712:
+		key, _, err = crypto.GenerateSecp256k1Key(rand.Reader)
+	case protocol.SchemeEd25519, "":
+		key, _, err = crypto.GenerateEd25519Key(rand.Reader)
+	default:
+		return nil, fmt.Errorf("identity error, unknown signing scheme: %s", scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("identity error, unable to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt returns the keystore JSON of the key encrypted with the
+// passphrase.
+func Encrypt(key crypto.PrivKey, passphrase string) ([]byte, error) {
+	switch key.Type() {
+	case crypto.Secp256k1:
+		raw, err := key.Raw()
+		if err != nil {
+			return nil, err
+		}
+		ecdsaKey, err := ethcrypto.ToECDSA(raw)
+		if err != nil {
+			return nil, fmt.Errorf("identity error, invalid secp256k1 key: %w", err)
+		}
+		return keystore.EncryptKey(&keystore.Key{
+			Id:         uuid.New(),
+			Address:    ethcrypto.PubkeyToAddress(ecdsaKey.PublicKey),
+			PrivateKey: ecdsaKey,
+		}, passphrase, scryptN, scryptP)
+	case crypto.Ed25519:
+		data, err := crypto.MarshalPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		id, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		cryptoJSON, err := keystore.EncryptDataV3(data, []byte(passphrase), scryptN, scryptP)
+		if err != nil {
+			return nil, fmt.Errorf("identity error, unable to encrypt key: %w", err)
+		}
+		return json.Marshal(libp2pKeyJSON{
+			Type:    keyTypeLibp2p,
+			PeerID:  id.String(),
+			Crypto:  cryptoJSON,
+			Id:      uuid.New().String(),
+			Version: version,
+		})
+	}
+	return nil, fmt.Errorf("identity error, unsupported key type: %s", key.Type())
+}
+
+// Decrypt returns the key of the keystore JSON.
+func Decrypt(data []byte, passphrase string) (crypto.PrivKey, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("identity error, invalid keystore: %w", err)
+	}
+	if header.Type == keyTypeLibp2p {
+		var k libp2pKeyJSON
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("identity error, invalid keystore: %w", err)
+		}
+		raw, err := keystore.DecryptDataV3(k.Crypto, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("identity error, unable to decrypt key: %w", err)
+		}
+		return crypto.UnmarshalPrivateKey(raw)
+	}
+	k, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("identity error, unable to decrypt key: %w", err)
+	}
+	return crypto.UnmarshalSecp256k1PrivateKey(ethcrypto.FromECDSA(k.PrivateKey))
+}
+
+// Load reads and decrypts the keystore file.
+func Load(path string, passphrase string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("identity error, unable to read %s: %w", path, err)
+	}
+	return Decrypt(data, passphrase)
+}
+
+// Save encrypts the key and writes it to a new keystore file. An existing
+// file is never overwritten.
+func Save(path string, key crypto.PrivKey, passphrase string) error {
+	data, err := Encrypt(key, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("identity error, unable to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("identity error, unable to create %s: %w", path, err)
+	}
+	_, err = file.Write(data)
+	if err = errors.Join(err, file.Close()); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("identity error, unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Passphrase returns the passphrase of the keystore. It's read from the
+// file if it's set, the trailing newline is removed, otherwise the
+// password is returned.
+func Passphrase(password string, file string) (string, error) {
+	if file == "" {
+		return password, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("identity error, unable to read passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
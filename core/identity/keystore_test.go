@@ -0,0 +1,123 @@
+package identity
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	pb "github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	// The standard parameters take a second and 256MB for every key
+	scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		scheme  string
+		keyType pb.KeyType
+	}{
+		{protocol.SchemeEIP712, pb.KeyType_Secp256k1},
+		{protocol.SchemeEd25519, pb.KeyType_Ed25519},
+		{"", pb.KeyType_Ed25519},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			key, err := Generate(tt.scheme)
+			if err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			if key.Type() != tt.keyType {
+				t.Fatalf("Generate() = %s key, want %s", key.Type(), tt.keyType)
+			}
+			path := filepath.Join(t.TempDir(), "keys", "node.json")
+			if err := Save(path, key, "secret"); err != nil {
+				t.Fatalf("Save() error: %v", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0o600 {
+				t.Errorf("Save() file mode = %v, want 0600", info.Mode().Perm())
+			}
+
+			loaded, err := Load(path, "secret")
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if !key.Equals(loaded) {
+				t.Error("Load() returned another key than the saved one")
+			}
+			if _, err := Load(path, "other"); err == nil {
+				t.Error("Load() with a wrong passphrase error = nil, want an error")
+			}
+			// An existing key is never overwritten
+			if err := Save(path, key, "secret"); err == nil {
+				t.Error("Save() of an existing file error = nil, want an error")
+			}
+		})
+	}
+}
+
+// TestKeystoreGethCompatible checks that secp256k1 keys can be imported to
+// geth, with the signer address of the node.
+func TestKeystoreGethCompatible(t *testing.T) {
+	key, err := Generate(protocol.SchemeEIP712)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encrypt(key, "secret")
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	gethKey, err := keystore.DecryptKey(data, "secret")
+	if err != nil {
+		t.Fatalf("keystore.DecryptKey() error: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := global.PeerIDToAddress(id); gethKey.Address != want {
+		t.Errorf("keystore address = %s, want the signer address %s", gethKey.Address, want)
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	for _, data := range []string{"", "{", `{"type":"libp2p","crypto":{}}`, `{"version":3}`} {
+		if _, err := Decrypt([]byte(data), "secret"); err == nil {
+			t.Errorf("Decrypt(%q) error = nil, want an error", data)
+		}
+	}
+	if _, err := Generate("rsa"); err == nil {
+		t.Error("Generate() of an unknown scheme error = nil, want an error")
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(path, []byte("from file\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		password, file string
+		want           string
+	}{
+		{"secret", "", "secret"},
+		// The file wins over the password, without its trailing newline
+		{"secret", path, "from file"},
+	}
+	for _, tt := range tests {
+		if got, err := Passphrase(tt.password, tt.file); err != nil || got != tt.want {
+			t.Errorf("Passphrase(%q, %q) = %q, %v, want %q", tt.password, tt.file, got, err, tt.want)
+		}
+	}
+	if _, err := Passphrase("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Passphrase() of a missing file error = nil, want an error")
+	}
+}
@@ -0,0 +1,188 @@
+// Package metrics contains the Prometheus collectors of the node. All
+// collectors are registered in Registry, which is served by Handler.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "gossip_price"
+
+// Registry is the registry of all collectors of the node.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+// Gossip metrics
+var (
+	// Peers is the number of connected peers.
+	Peers = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "peers",
+		Help:      "Number of connected peers.",
+	})
+	// MessagesReceived counts the messages delivered from other peers.
+	MessagesReceived = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "messages_received_total",
+		Help:      "Number of messages received from other peers.",
+	}, []string{"topic"})
+	// MessagesPublished counts the messages published by the node.
+	MessagesPublished = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "messages_published_total",
+		Help:      "Number of messages published by the node.",
+	}, []string{"topic"})
+	// MessagesRejected counts the messages rejected by the validator.
+	MessagesRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "messages_rejected_total",
+		Help:      "Number of messages rejected by the topic validator.",
+	}, []string{"topic", "reason"})
+	// MessagesIgnored counts the messages ignored by the validator.
+	MessagesIgnored = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "messages_ignored_total",
+		Help:      "Number of messages ignored by the topic validator.",
+	}, []string{"topic", "reason"})
+	// ConnectionsDenied counts the connections denied by the connection gater.
+	ConnectionsDenied = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "connections_denied_total",
+		Help:      "Number of connections denied by the connection gater.",
+	}, []string{"reason"})
+	// ValidatorLatency is the time spent validating a message.
+	ValidatorLatency = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "gossip",
+		Name:      "validator_duration_seconds",
+		Help:      "Time spent validating a message.",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 12),
+	}, []string{"topic"})
+)
+
+// Consensus metrics
+var (
+	// SignaturesPerMessage is the number of signatures of stored messages.
+	SignaturesPerMessage = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consensus",
+		Name:      "signatures_per_message",
+		Help:      "Number of signatures of the stored messages.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 15),
+	}, []string{"pair"})
+	// TimeToQuorum is the time from the first signature of a message
+	// until it reaches the quorum.
+	TimeToQuorum = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consensus",
+		Name:      "time_to_quorum_seconds",
+		Help:      "Time from the first signature of a message until the quorum is reached.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"pair"})
+	// TimeToFinalization is the time from the first signature of a
+	// message until it is stored.
+	TimeToFinalization = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consensus",
+		Name:      "time_to_finalization_seconds",
+		Help:      "Time from the first signature of a message until it is stored.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"pair"})
+	// ObservationsRejected counts the observations rejected because they
+	// deviate too much from the other observations of the message.
+	ObservationsRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consensus",
+		Name:      "observations_rejected_total",
+		Help:      "Number of observations deviating beyond the max spread.",
+	}, []string{"pair"})
+	// PendingMessages is the number of messages collecting signatures.
+	PendingMessages = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consensus",
+		Name:      "pending_messages",
+		Help:      "Number of messages collecting signatures in the engine.",
+	}, []string{"pair"})
+)
+
+// Persistence metrics
+var (
+	// DBInsertErrors counts the failed inserts of rates.
+	DBInsertErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "insert_errors_total",
+		Help:      "Number of failed rate inserts.",
+	})
+)
+
+// Signer registry metrics
+var (
+	// RegistrySigners is the number of signer keys which are valid now.
+	RegistrySigners = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "registry",
+		Name:      "signers",
+		Help:      "Number of valid signer keys in the signer registry.",
+	})
+	// RegistryReloadErrors counts the failed reloads of the registry.
+	RegistryReloadErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "registry",
+		Name:      "reload_errors_total",
+		Help:      "Number of failed reloads of the signer registry.",
+	})
+)
+
+// Signing daemon metrics
+var (
+	// SignerRequests counts the signing requests of the signing daemon.
+	SignerRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "signer",
+		Name:      "requests_total",
+		Help:      "Number of signing requests by result: signed, denied or invalid.",
+	}, []string{"result"})
+)
+
+// Price source metrics
+var (
+	// PriceFetchLatency is the time spent fetching a quote.
+	PriceFetchLatency = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "price",
+		Name:      "fetch_duration_seconds",
+		Help:      "Time spent fetching a quote from a price source.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"source"})
+	// PriceFetchFailures counts the failed or stale quotes.
+	PriceFetchFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "price",
+		Name:      "fetch_failures_total",
+		Help:      "Number of failed or stale quotes of a price source.",
+	}, []string{"source"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns the HTTP handler serving the metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gossip-price/core/consensus"
+	"gossip-price/core/consensus/db"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"gossip-price/core/price"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHistoryLimit and maxHistoryLimit limit the number of rates
+// returned by the history endpoint
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// APIConfig is the configuration of the HTTP API server.
+type APIConfig struct {
+	// Address is the listen address, e.g. ":8080"
+	Address      string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// API is the read-only HTTP API of the node. It serves the following
+// endpoints, where pairs are written as BASE-QUOTE, e.g. ETH-USD:
+//
+//	GET /v1/prices                        latest stored price of every pair
+//	GET /v1/prices/{pair}                 latest stored price of the pair
+//	GET /v1/prices/{pair}/history         stored prices in the from/to range
+//	GET /v1/rates/{id}                    stored rate with its signatures
+//	GET /v1/pending                       messages which are not stored yet
+//
+// The server also serves /metrics, and /debug/peers with the GossipSub
+// scores of the connected peers.
+type API struct {
+	srv      *http.Server
+	mux      *http.ServeMux
+	engine   *consensus.Engine
+	database db.RateRepository
+	pairs    []price.PairConfig
+}
+
+type rateResponse struct {
+	ID             string             `json:"id"`
+	Pair           string             `json:"pair"`
+	Price          string             `json:"price"`
+	FirstSigner    string             `json:"first_signer"`
+	Trigger        string             `json:"trigger"`
+	Signatures     []db.SignatureData `json:"signatures,omitempty"`
+	LastSignedTime time.Time          `json:"last_signed_time"`
+	CreatedTime    time.Time          `json:"created_time"`
+}
+
+type pendingResponse struct {
+	ID          string       `json:"id"`
+	Pair        string       `json:"pair"`
+	FirstSigner string       `json:"first_signer"`
+	SignedCount int          `json:"signed_count"`
+	Messages    []pendingMsg `json:"messages"`
+}
+
+type pendingMsg struct {
+	Price      float64   `json:"price"`
+	Signer     string    `json:"signer"`
+	Signature  string    `json:"signature"`
+	SignedTime time.Time `json:"signed_time"`
+}
+
+// NewAPI returns a new API server.
+func NewAPI(c APIConfig, engine *consensus.Engine, pairs []price.PairConfig) *API {
+	a := &API{
+		mux:      http.NewServeMux(),
+		engine:   engine,
+		database: engine.Database(),
+		pairs:    pairs,
+	}
+	a.mux.HandleFunc("/v1/prices", a.handleLatestAll)
+	a.mux.HandleFunc("/v1/prices/", a.handlePair)
+	a.mux.HandleFunc("/v1/rates/", a.handleRate)
+	a.mux.HandleFunc("/v1/pending", a.handlePending)
+	a.srv = &http.Server{
+		Addr:         c.Address,
+		Handler:      a.mux,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+	return a
+}
+
+// Handle registers an additional handler on the API server. It must be
+// called before Start.
+func (a *API) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+// Start starts the server and shuts it down when the context is canceled.
+func (a *API) Start(ctx context.Context) {
+	go func() {
+		log.Printf("HTTP API listening on %s", a.srv.Addr)
+		err := a.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP API error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = a.srv.Shutdown(shutdownCtx)
+	}()
+}
+
+func (a *API) handleLatestAll(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	rates := make([]rateResponse, 0, len(a.pairs))
+	for _, p := range a.pairs {
+		rate, err := a.database.LatestRate(p.Pair.String())
+		if errors.Is(err, global.ErrRateNotFound) {
+			continue
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		rates = append(rates, newRateResponse(rate, false))
+	}
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// handlePair serves /v1/prices/{pair} and /v1/prices/{pair}/history
+func (a *API) handlePair(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/prices/"), "/")
+	pair, err := parsePathPair(parts[0])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		rate, err := a.database.LatestRate(pair.String())
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newRateResponse(rate, false))
+	case len(parts) == 2 && parts[1] == "history":
+		a.handleHistory(w, r, pair)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// handleHistory returns the rates of the pair in the from/to range. Both
+// parameters accept RFC 3339 times or unix seconds, and default to the
+// last 24 hours.
+func (a *API) handleHistory(w http.ResponseWriter, r *http.Request, pair price.Pair) {
+	query := r.URL.Query()
+	to, err := parseTime(query.Get("to"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	from, err := parseTime(query.Get("from"), to.Add(-24*time.Hour))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := defaultHistoryLimit
+	if l := query.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxHistoryLimit {
+			writeError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+	}
+	rates, err := a.database.RatesBetween(pair.String(), from, to, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	res := make([]rateResponse, 0, len(rates))
+	for i := range rates {
+		res = append(res, newRateResponse(&rates[i], false))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (a *API) handleRate(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v1/rates/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	rate, err := a.database.GetRate(id)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRateResponse(rate, true))
+}
+
+func (a *API) handlePending(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	var pairName string
+	if p := r.URL.Query().Get("pair"); p != "" {
+		pair, err := parsePathPair(p)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		pairName = pair.String()
+	}
+	pending := a.engine.Pending(pairName)
+	res := make([]pendingResponse, 0, len(pending))
+	for _, p := range pending {
+		msgs := make([]pendingMsg, 0, len(p.Messages))
+		for _, m := range p.Messages {
+			msgs = append(msgs, pendingMsg{
+				Price:      m.Price,
+				Signer:     m.Signer.String(),
+				Signature:  m.Signature.String(),
+				SignedTime: m.SignedTime,
+			})
+		}
+		res = append(res, pendingResponse{
+			ID:          p.MsgId,
+			Pair:        p.Pair,
+			FirstSigner: p.FirstSigner.String(),
+			SignedCount: len(p.Messages),
+			Messages:    msgs,
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func newRateResponse(rate *db.Rate, withSignatures bool) rateResponse {
+	res := rateResponse{
+		ID:             rate.ID,
+		Pair:           rate.Pair,
+		Price:          rate.Price,
+		FirstSigner:    rate.First_Signer,
+		Trigger:        rate.Trigger,
+		LastSignedTime: rate.LastSigned_Time,
+		CreatedTime:    rate.Created_Time,
+	}
+	if withSignatures {
+		res.Signatures = rate.Signatures
+	}
+	return res
+}
+
+// peerScoresHandler serves the GossipSub scores of the connected peers,
+// lowest first, so misbehaving peers are on top.
+func peerScoresHandler(scores func() []protocol.PeerScore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		res := scores()
+		if res == nil {
+			writeError(w, http.StatusNotFound, errors.New("peer scoring is disabled"))
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+// parsePathPair parses a pair written as BASE-QUOTE
+func parsePathPair(s string) (price.Pair, error) {
+	return price.ParsePair(strings.Replace(s, "-", "/", 1))
+}
+
+// parseTime parses RFC 3339 times or unix seconds, returning def for an
+// empty string
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+	return true
+}
+
+func statusOf(err error) int {
+	if errors.Is(err, global.ErrRateNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("HTTP API error, unable to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -2,43 +2,312 @@ package server
 
 import (
 	"context"
-	"github.com/google/uuid"
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/pkg/errors"
 	"gossip-price/core/consensus"
+	"gossip-price/core/consensus/db"
 	"gossip-price/core/global"
 	protocol "gossip-price/core/gossip"
+	"gossip-price/core/identity"
+	"gossip-price/core/metrics"
+	"gossip-price/core/price"
+	"gossip-price/core/publisher"
+	"gossip-price/core/registry"
+	"gossip-price/core/signer"
+	"gossip-price/core/statesync"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 type Server struct {
-	ctx       context.Context
-	bootStrap bool
-	protocol  *protocol.Protocol
-	engine    *consensus.Engine
+	ctx        context.Context
+	bootStrap  bool
+	protocol   *protocol.Protocol
+	engine     *consensus.Engine
+	aggregator *price.Aggregator
+	publisher  *publisher.Publisher
+	api        *API
+	signers    *registry.Registry
+	pairs      []price.PairConfig
+	rounds     map[string]consensus.Rounds
+	topics     map[string]string
+	signing    map[string]bool
+	signingMu  sync.Mutex
+	triggers   *triggers
+	stateSync  *statesync.Service
 }
 
 func NewGossipServer() (*Server, error) {
+	pairs, err := price.ParsePairConfigs(global.GPPairs, price.PairConfig{
+		Interval:  time.Duration(global.GPFetchPriceInterval) * time.Second,
+		Deviation: global.GPDeviation,
+		Heartbeat: time.Duration(global.GPHeartbeat) * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		titles    []string
+		pairNames []string
+		topics    = make(map[string]string, len(pairs))
+		bounds    = make(map[string]protocol.PriceBounds, len(pairs))
+		intervals = make(map[string]time.Duration, len(pairs))
+		rounds    = make(map[string]consensus.Rounds, len(pairs))
+		epoch     = time.Unix(int64(global.GPRoundEpoch), 0)
+	)
+	for _, p := range pairs {
+		title := topicName(p.Pair)
+		titles = append(titles, title)
+		pairNames = append(pairNames, p.Pair.String())
+		topics[title] = p.Pair.String()
+		rounds[p.Pair.String()] = consensus.Rounds{Epoch: epoch, Interval: p.Interval}
+		bounds[p.Pair.String()] = protocol.PriceBounds{Min: p.Min, Max: p.Max}
+		intervals[title] = p.Interval
+	}
+
+	signers, err := newRegistry()
+	if err != nil {
+		return nil, err
+	}
+
 	config := protocol.Config{
 		IsBootstrap:      global.GPBootstrapMode,
-		Titles:           "ethPrice",
+		Signers:          signers,
+		MaxMessageAge:    time.Duration(global.GPMessageMaxAge) * time.Second,
+		MaxClockSkew:     time.Duration(global.GPMessageMaxSkew) * time.Second,
+		PriceBounds:      bounds,
+		PeerScore:        newScoreConfig(intervals),
+		Titles:           titles,
+		Scheme:           global.GPSigningScheme,
 		ConnectedAddress: []string{global.GPConnectionAddress},
-		BootstrapAddress: []string{global.GPBootstrapAddress},
+		BootstrapAddress: splitList(global.GPBootstrapAddress),
+		Rendezvous:       global.GPRendezvous,
+		MDNS:             global.GPMdns,
+		Gater: protocol.GaterConfig{
+			AllowPeers: splitList(global.GPAllowPeers),
+			DenyPeers:  splitList(global.GPDenyPeers),
+			AllowCIDRs: splitList(global.GPAllowCIDRs),
+			DenyCIDRs:  splitList(global.GPDenyCIDRs),
+		},
+	}
+
+	config.NodeKey, err = newNodeKey()
+	if err != nil {
+		return nil, err
+	}
+	if !global.GPBootstrapMode {
+		if config.Signer, err = newSigner(); err != nil {
+			return nil, err
+		}
+		// Without node key the node address is random, so it never
+		// matches the signer
+		var node common.Address
+		if config.NodeKey != nil {
+			id, err := peer.IDFromPrivateKey(config.NodeKey)
+			if err != nil {
+				return nil, err
+			}
+			node = global.PeerIDToAddress(id)
+		}
+		if err = checkSigner(config.Signer, node, signers); err != nil {
+			return nil, err
+		}
+	}
+	if global.GPPskFile != "" {
+		config.PSK, err = protocol.LoadPSK(global.GPPskFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources, err := price.NewSources(global.GPPriceSources)
+	if err != nil {
+		return nil, err
 	}
+	aggregator := price.NewAggregator(
+		sources,
+		time.Duration(global.GPPriceMaxAge)*time.Second,
+		global.GPPriceMinSources,
+		time.Duration(global.GPPriceFetchTimeout)*time.Second,
+	)
 
-	en := consensus.NewEngine()
+	database, err := db.Open(global.GPDatabaseUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "New Gossip Server error")
+	}
+	if global.GPAutoMigrate {
+		if err = database.Migrate(context.Background()); err != nil {
+			return nil, errors.Wrap(err, "New Gossip Server error")
+		}
+	}
+
+	var wal *consensus.WAL
+	if global.GPWalPath != "" {
+		if wal, err = consensus.OpenWAL(global.GPWalPath); err != nil {
+			return nil, errors.Wrap(err, "New Gossip Server error")
+		}
+	}
+	en, err := consensus.NewEngine(consensus.Config{
+		Pairs:    pairNames,
+		Database: database,
+		Signers:  signers,
+		Aggregation: consensus.Aggregation{
+			Method:    global.GPAggregation,
+			Trim:      float64(global.GPTrimPercent) / 100,
+			MaxSpread: global.GPMaxSpread,
+		},
+		PendingTTL: time.Duration(global.GPPendingTTL) * time.Second,
+		MaxPending: global.GPMaxPending,
+		WAL:        wal,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "New Gossip Server error")
+	}
 	pro, err := protocol.New(config)
-	if err != nil || en == nil {
-		return nil, errors.New("New Gossip Server error")
+	if err != nil {
+		return nil, errors.Wrap(err, "New Gossip Server error")
+	}
+	if signers != nil && !global.GPBootstrapMode && !signers.IsAuthorized(pro.Address()) {
+		log.Printf("Node signer %s is not valid in the signer registry, its signatures are ignored", pro.Address())
+	}
+
+	// The triggers start from the last stored prices, so a restart does not
+	// start a new round of every pair
+	trig := newTriggers()
+	for _, name := range pairNames {
+		rate, err := database.LatestRate(name)
+		if err != nil {
+			continue
+		}
+		if p, err := strconv.ParseFloat(rate.Price, 64); err == nil {
+			trig.update(name, p, rate.LastSigned_Time)
+		}
+	}
+	en.AddFinalizedHandler(func(rate consensus.FinalizedRate) {
+		var at time.Time
+		for _, msg := range rate.Messages {
+			if msg.SignedTime.After(at) {
+				at = msg.SignedTime
+			}
+		}
+		trig.update(rate.Pair, rate.Price, at)
+	})
+
+	var pub *publisher.Publisher
+	if global.GPPublisher && !global.GPBootstrapMode {
+		pub, err = newPublisher()
+		if err != nil {
+			return nil, err
+		}
+		en.AddFinalizedHandler(pub.Enqueue)
+	}
+
+	var api *API
+	if global.GPHttpAddress != "" {
+		api = NewAPI(APIConfig{
+			Address:      global.GPHttpAddress,
+			ReadTimeout:  time.Duration(global.GPHttpReadTimeout) * time.Second,
+			WriteTimeout: time.Duration(global.GPHttpWriteTimeout) * time.Second,
+			IdleTimeout:  time.Duration(global.GPHttpIdleTimeout) * time.Second,
+		}, en, pairs)
+		api.Handle("/metrics", metrics.Handler())
+		api.Handle("/debug/peers", peerScoresHandler(pro.PeerScores))
 	}
 
 	return &Server{
-		bootStrap: global.GPBootstrapMode,
-		protocol:  pro,
-		engine:    en,
+		bootStrap:  global.GPBootstrapMode,
+		protocol:   pro,
+		engine:     en,
+		aggregator: aggregator,
+		publisher:  pub,
+		api:        api,
+		signers:    signers,
+		pairs:      pairs,
+		rounds:     rounds,
+		topics:     topics,
+		signing:    make(map[string]bool),
+		triggers:   trig,
 	}, nil
 }
 
+// newPublisher returns the on-chain publisher configured from the
+// environment
+func newPublisher() (*publisher.Publisher, error) {
+	if !common.IsHexAddress(global.GPOracleAddress) {
+		return nil, errors.New("New Publisher error, invalid oracle address")
+	}
+	key, err := ethcrypto.HexToECDSA(strings.TrimPrefix(global.GPPublisherKey, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "New Publisher error, invalid private key")
+	}
+	// The contract verifies the signatures with its own domain, so the
+	// messages signed with another domain would revert every poke
+	domain := protocol.DefaultDomain
+	if domain.VerifyingContract != common.HexToAddress(global.GPOracleAddress) {
+		return nil, errors.Errorf("New Publisher error, verifying contract %s differs from the oracle address %s", domain.VerifyingContract.Hex(), global.GPOracleAddress)
+	}
+	client, err := ethclient.Dial(global.GPEthRpcUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "New Publisher error")
+	}
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "New Publisher error, unable to get chain ID")
+	}
+	if chainID.Cmp(domain.ChainID) != 0 {
+		return nil, errors.Errorf("New Publisher error, chain ID %s of the domain differs from the chain ID %s of the node", domain.ChainID, chainID)
+	}
+	return publisher.New(client, publisher.Config{
+		Contract:       common.HexToAddress(global.GPOracleAddress),
+		Key:            key,
+		ReceiptTimeout: time.Duration(global.GPPublisherTimeout) * time.Second,
+		Retries:        global.GPPublisherRetries,
+	})
+}
+
+// newRegistry returns the signer registry configured from the environment,
+// loaded from the signer file and the committee contract. It returns nil if
+// neither is configured, so any signer is accepted.
+func newRegistry() (*registry.Registry, error) {
+	var sources []registry.Source
+	if global.GPSignersFile != "" {
+		sources = append(sources, registry.NewFileSource(global.GPSignersFile))
+	}
+	if global.GPSignersContract != "" {
+		if !common.IsHexAddress(global.GPSignersContract) {
+			return nil, errors.New("New Registry error, invalid contract address")
+		}
+		client, err := ethclient.Dial(global.GPEthRpcUrl)
+		if err != nil {
+			return nil, errors.Wrap(err, "New Registry error")
+		}
+		src, err := registry.NewContractSource(client, common.HexToAddress(global.GPSignersContract))
+		if err != nil {
+			return nil, errors.Wrap(err, "New Registry error")
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	signers := registry.New(sources...)
+	if err := signers.Reload(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "New Registry error")
+	}
+	return signers, nil
+}
+
 func (s *Server) Start(ctx context.Context) error {
 	if s.bootStrap {
 		log.Print("Bootstrap server started")
@@ -50,57 +319,329 @@ func (s *Server) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if s.signers != nil {
+		s.signers.Start(ctx, time.Duration(global.GPSignersReload)*time.Second)
+	}
+	if s.api != nil {
+		s.api.Start(ctx)
+	}
 	if !s.bootStrap {
-		go s.Broadcast()
+		for _, p := range s.pairs {
+			go s.Broadcast(p)
+		}
 		go s.messageLoop()
+		go s.reloadOnHangup(ctx)
+		s.stateSync = statesync.New(s.protocol.Host(), s.engine, s.pairNames())
+		go s.catchUp()
+		if s.publisher != nil {
+			s.publisher.Start(ctx)
+		}
 		s.engine.StartEngine(ctx)
 	}
 	return nil
 }
 
-func (s *Server) Broadcast() {
+// Broadcast checks the price of the pair at the start of every round of
+// the pair, and starts the round if the price deviates from the last price
+// or if the heartbeat of the pair elapsed. Rounds are derived from the
+// shared epoch, so every node signs the same message id in a round.
+func (s *Server) Broadcast(cfg price.PairConfig) {
+	rounds := s.rounds[cfg.Pair.String()]
 	for {
+		round := rounds.At(time.Now()) + 1
 		select {
 		case <-s.ctx.Done():
-			break
-		case <-time.After(time.Duration(global.GPFetchPriceInterval) * time.Second):
-			price, _ := global.GetETHPrice()
-			if price != 0 {
-				id := uuid.New().String()
-				p, _ := s.protocol.Broadcast(&protocol.ProtocolMessage{
-					MsgId: id,
-					Price: price,
-				})
-				if !s.engine.CheckAlreadySigned(p.(*protocol.ProtocolMessage).MsgId, p.(*protocol.ProtocolMessage).Signer) {
-					s.engine.Append(*p.(*protocol.ProtocolMessage))
-				}
-			}
+			return
+		case <-time.After(time.Until(rounds.Start(round))):
+			s.observe(cfg, consensus.RoundID(cfg.Pair.String(), round), "")
+		}
+	}
+}
+
+// observe fetches the price of the pair, and signs and broadcasts it as the
+// observation of the node for the message id. If trigger is empty, the
+// node starts the round only if one of the triggers of the pair fires,
+// otherwise it joins the round started by another node with the trigger.
+// Every message is observed only once.
+func (s *Server) observe(cfg price.PairConfig, id string, trigger string) {
+	pair := cfg.Pair
+	key := pair.String() + "/" + id
+	s.signingMu.Lock()
+	if s.signing[key] || s.engine.CheckAlreadySigned(pair.String(), id, s.protocol.Address()) {
+		s.signingMu.Unlock()
+		return
+	}
+	s.signing[key] = true
+	s.signingMu.Unlock()
+	defer func() {
+		s.signingMu.Lock()
+		delete(s.signing, key)
+		s.signingMu.Unlock()
+	}()
 
+	res, err := s.aggregator.Fetch(s.ctx, pair)
+	for name, err := range res.Failures {
+		log.Printf("Price source %s failed for %s: %v", name, pair, err)
+	}
+	if err != nil {
+		log.Printf("Fetching %s price failed: %v", pair, err)
+		return
+	}
+	if res.Price == 0 {
+		return
+	}
+	if trigger == "" {
+		if trigger = s.triggers.check(cfg, res.Price, time.Now()); trigger == "" {
+			return
 		}
+		log.Printf("Starting round %s of %s, trigger: %s", id, pair, trigger)
 	}
+	p, err := s.protocol.Broadcast(topicName(pair), &protocol.ProtocolMessage{
+		MsgId:   id,
+		Pair:    pair.String(),
+		Price:   res.Price,
+		Trigger: trigger,
+	})
+	if err != nil {
+		log.Printf("Broadcasting %s price failed: %v", pair, err)
+		return
+	}
+	s.engine.Append(*p.(*protocol.ProtocolMessage))
 }
 
+// messageLoop collects the observations of the other nodes. The node adds
+// its own observation to every message it did not sign yet, instead of
+// signing the price of the other node.
 func (s *Server) messageLoop() {
 	ch := s.protocol.Message()
 	for {
 		select {
 		case <-s.ctx.Done():
-			break
+			return
 		case msg := <-ch:
 			priceMsg, ok := msg.Message.(*protocol.ProtocolMessage)
 			if !ok {
 				continue
 			}
-			if s.engine.CheckAlreadySigned(priceMsg.MsgId, priceMsg.Signer) {
+			// Messages must be published to the topic of their own pair
+			if s.topics[msg.Topic] != priceMsg.Pair {
 				continue
 			}
-			if s.engine.Append(*priceMsg) {
-				_, _ = s.protocol.Broadcast(priceMsg)
+			s.handleMessage(priceMsg)
+		}
+	}
+}
+
+// handleMessage appends the verified message of another node to the
+// engine, and adds the observation of the node to it if it needs more
+// signatures.
+func (s *Server) handleMessage(priceMsg *protocol.ProtocolMessage) {
+	if !s.isCurrentRound(priceMsg) {
+		return
+	}
+	if s.engine.CheckAlreadySigned(priceMsg.Pair, priceMsg.MsgId, priceMsg.Signer) {
+		return
+	}
+	if s.engine.Append(*priceMsg) {
+		cfg, ok := s.pairConfig(priceMsg.Pair)
+		if !ok {
+			return
+		}
+		trigger := priceMsg.Trigger
+		if trigger == "" {
+			trigger = TriggerUnknown
+		}
+		go s.observe(cfg, priceMsg.MsgId, trigger)
+	}
+}
+
+// pairNames returns the names of the configured pairs.
+func (s *Server) pairNames() []string {
+	names := make([]string, 0, len(s.pairs))
+	for _, cfg := range s.pairs {
+		names = append(names, cfg.Pair.String())
+	}
+	return names
+}
+
+// newNodeKey loads the key of the node from the keystore file. It returns
+// nil if no keystore is configured, so a random key is used.
+func newNodeKey() (crypto.PrivKey, error) {
+	if global.GPNodeKeyFile == "" {
+		if !global.GPBootstrapMode && global.GPRemoteSigner == "" && global.GPSigningKeyFile == "" {
+			log.Printf("No node key file is configured, the signer address changes on every start")
+		}
+		return nil, nil
+	}
+	passphrase, err := identity.Passphrase(global.GPNodeKeyPassword, global.GPNodeKeyPassFile)
+	if err != nil {
+		return nil, err
+	}
+	return identity.Load(global.GPNodeKeyFile, passphrase)
+}
+
+// newSigner returns the signer configured from the environment: the remote
+// signer if GP_REMOTESIGNER is set, or the key of GP_SIGNINGKEYFILE. It
+// returns nil if neither is set, so the node signs with its node key.
+func newSigner() (protocol.Signer, error) {
+	if global.GPRemoteSigner != "" {
+		return signer.NewRemote(context.Background(), signer.RemoteConfig{
+			URL: global.GPRemoteSigner,
+			TLS: signer.TLSConfig{
+				CertFile: global.GPSignerTLSCert,
+				KeyFile:  global.GPSignerTLSKey,
+				CAFile:   global.GPSignerTLSCA,
+			},
+			Timeout: time.Duration(global.GPSignerTimeout) * time.Second,
+		})
+	}
+	if global.GPSigningKeyFile == "" {
+		return nil, nil
+	}
+	passphrase, err := identity.Passphrase(global.GPNodeKeyPassword, global.GPNodeKeyPassFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := identity.Load(global.GPSigningKeyFile, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewLocalSigner(key)
+}
+
+// checkSigner returns an error if the other nodes cannot verify the
+// signatures of the signer. Ed25519 signatures do not recover their signer,
+// so without signer registry they are verified with the key of the node
+// which sent them, and the signer must be the node key.
+func checkSigner(sig protocol.Signer, node common.Address, signers *registry.Registry) error {
+	if sig == nil || signers != nil || sig.Scheme() != protocol.SchemeEd25519 || sig.Address() == node {
+		return nil
+	}
+	return errors.Errorf("Signer error, Ed25519 signer %s is not the node key, it requires GP_SIGNERSFILE or GP_SIGNERSCONTRACT", sig.Address())
+}
+
+// ReloadSigner loads the signer again and swaps it in the running
+// protocol, so a rotated key is used without a restart. The node key is the
+// libp2p identity of the node and cannot be reloaded, so it requires
+// GP_SIGNINGKEYFILE or GP_REMOTESIGNER. With a signer registry, the new key
+// must be valid now or activated in the future, so a key can be swapped in
+// before its activation but an unknown or expired key is refused.
+func (s *Server) ReloadSigner() error {
+	if s.bootStrap {
+		return errors.New("Reload Signer error, bootstrap nodes do not sign")
+	}
+	sig, err := newSigner()
+	if err != nil {
+		return errors.Wrap(err, "Reload Signer error")
+	}
+	if sig == nil {
+		return errors.New("Reload Signer error, neither GP_SIGNINGKEYFILE nor GP_REMOTESIGNER is set")
+	}
+	if err = checkSigner(sig, global.PeerIDToAddress(s.protocol.Host().ID()), s.signers); err != nil {
+		closeSigner(sig)
+		return errors.Wrap(err, "Reload Signer error")
+	}
+	var activatesAt time.Time
+	if s.signers != nil && !s.signers.IsAuthorized(sig.Address()) {
+		entry, ok := s.signers.Signer(sig.Address())
+		if !ok || !entry.ActivatesAt.After(time.Now()) {
+			closeSigner(sig)
+			return errors.Errorf("Reload Signer error, %s is not valid in the signer registry", sig.Address())
+		}
+		activatesAt = entry.ActivatesAt
+	}
+	prev := s.protocol.Signer()
+	s.protocol.SetSigner(sig)
+	closeSigner(prev)
+	log.Printf("Node signer reloaded, signing as %s instead of %s", sig.Address(), prev.Address())
+	if !activatesAt.IsZero() {
+		log.Printf("Node signer %s is valid from %s, its signatures are ignored until then", sig.Address(), activatesAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// closeSigner releases the connections of a signer which is not used
+// anymore, if it has any.
+func closeSigner(sig protocol.Signer) {
+	if c, ok := sig.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// reloadOnHangup reloads the signer on every SIGHUP until the context is
+// canceled.
+func (s *Server) reloadOnHangup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := s.ReloadSigner(); err != nil {
+				log.Printf("Reloading node signer failed, the previous signer is kept: %v", err)
 			}
 		}
 	}
 }
 
+// newScoreConfig returns the peer scoring configuration of the topics, or
+// nil if peer scoring is disabled.
+func newScoreConfig(topics map[string]time.Duration) *protocol.ScoreConfig {
+	if !global.GPPeerScore {
+		return nil
+	}
+	return &protocol.ScoreConfig{
+		Topics:                      topics,
+		IPColocationThreshold:       global.GPScoreIPColocation,
+		MeshDeliveriesThreshold:     float64(global.GPScoreMeshDelivery),
+		GossipThreshold:             float64(global.GPScoreGossip),
+		PublishThreshold:            float64(global.GPScorePublish),
+		GraylistThreshold:           float64(global.GPScoreGraylist),
+		AcceptPXThreshold:           float64(global.GPScoreAcceptPX),
+		OpportunisticGraftThreshold: float64(global.GPScoreGraft),
+	}
+}
+
+// splitList splits a comma separated list, skipping the empty items.
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// pairConfig returns the configuration of the pair.
+func (s *Server) pairConfig(pair string) (price.PairConfig, bool) {
+	for _, cfg := range s.pairs {
+		if cfg.Pair.String() == pair {
+			return cfg, true
+		}
+	}
+	return price.PairConfig{}, false
+}
+
+// isCurrentRound returns true if the message id is the round id of the
+// message pair, and the round is the current, the previous or the next
+// round. Adjacent rounds are accepted to tolerate clock skew between nodes.
+func (s *Server) isCurrentRound(msg *protocol.ProtocolMessage) bool {
+	pair, round, err := consensus.ParseRoundID(msg.MsgId)
+	if err != nil || pair != msg.Pair {
+		return false
+	}
+	current := s.rounds[msg.Pair].At(time.Now())
+	return round+1 >= current && round <= current+1
+}
+
 func (s *Server) Wait() <-chan error {
 	return s.protocol.Wait()
 }
+
+// topicName returns the gossip topic of the pair, e.g. "price/ETH-USD"
+func topicName(pair price.Pair) string {
+	return "price/" + strings.ToUpper(pair.Base+"-"+pair.Quote)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"gossip-price/core/statesync"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Values of the catch up after the start:
+const (
+	// syncWait is the maximum time to wait for peers supporting the sync
+	// protocol.
+	syncWait = 30 * time.Second
+	// syncPeers is the number of peers the state is requested from.
+	syncPeers = 3
+)
+
+// catchUp requests the pending messages and the latest stored rates from
+// the peers after the node is started, so the node does not have to wait
+// for the next gossip messages.
+func (s *Server) catchUp() {
+	var peers []peer.ID
+	deadline := time.Now().Add(syncWait)
+	for len(peers) == 0 && time.Now().Before(deadline) {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+			peers = s.stateSync.SupportingPeers()
+		}
+	}
+	if len(peers) > syncPeers {
+		peers = peers[:syncPeers]
+	}
+	for _, p := range peers {
+		s.syncFrom(s.ctx, p)
+	}
+}
+
+// syncFrom requests the state of the peer. Every pending message runs
+// through the validator pipeline of its topic, as if it was received by
+// gossip from its signer. Every signature of a rate is verified with the
+// key which was valid at its signed time.
+func (s *Server) syncFrom(ctx context.Context, p peer.ID) {
+	res, err := s.stateSync.Request(ctx, p, statesync.Request{Type: statesync.RequestPending})
+	if err != nil {
+		log.Printf("Syncing pending messages failed: %v", err)
+	} else {
+		count := 0
+		for _, pending := range res.Pending {
+			for _, msg := range pending.Messages {
+				if msg.MsgId != pending.MsgId || msg.Pair != pending.Pair {
+					continue
+				}
+				if err := s.validate(ctx, p, msg); err != nil {
+					log.Printf("Skipped synced message(%s) of %s: %v", msg.MsgId, msg.Signer, err)
+					continue
+				}
+				s.handleMessage(msg)
+				count++
+			}
+		}
+		log.Printf("Synced %d signatures of %d pending messages from %s", count, len(res.Pending), p)
+	}
+
+	res, err = s.stateSync.Request(ctx, p, statesync.Request{Type: statesync.RequestLatest})
+	if err != nil {
+		log.Printf("Syncing latest rates failed: %v", err)
+		return
+	}
+	for _, rate := range res.Rates {
+		if _, ok := s.pairConfig(rate.Pair); !ok || s.engine.Database().ExistCheck(rate.ID) {
+			continue
+		}
+		s.importRate(rate)
+	}
+}
+
+// importRate stores the rate of a peer, if it's signed by enough
+// authorized signers. Every signature is verified with the key which was
+// valid at its own signed time, so rates signed before a key rotation are
+// still accepted. Only the signatures and the trigger are taken from the
+// peer, the engine computes the rest of the rate from the verified
+// signatures.
+func (s *Server) importRate(rate statesync.Rate) {
+	var msgs []protocol.ProtocolMessage
+	for _, msg := range rate.SignedMessages() {
+		if s.signers != nil && !s.signers.IsAuthorizedAt(msg.Signer, msg.SignedTime) {
+			continue
+		}
+		if err := s.verifyAt(msg, msg.SignedTime); err != nil {
+			continue
+		}
+		msgs = append(msgs, *msg)
+	}
+	trigger := rate.Trigger
+	if trigger != TriggerDeviation && trigger != TriggerHeartbeat {
+		trigger = TriggerUnknown
+	}
+	stored, err := s.engine.Import(rate.ID, rate.Pair, trigger, rate.LastSignedTime, msgs)
+	if err != nil {
+		log.Printf("Skipped synced rate(%s) of %s: %v", rate.ID, rate.Pair, err)
+		return
+	}
+	if p, err := strconv.ParseFloat(stored.Price, 64); err == nil {
+		s.triggers.update(stored.Pair, p, stored.LastSigned_Time)
+	}
+}
+
+// validate runs the synced message through the validator pipeline of its
+// topic. The message is attributed to its signer if its key is known, so
+// Ed25519 messages are verified like the ones the signer publishes itself,
+// otherwise to the peer it's synced from.
+func (s *Server) validate(ctx context.Context, from peer.ID, msg *protocol.ProtocolMessage) error {
+	cfg, ok := s.pairConfig(msg.Pair)
+	if !ok {
+		return fmt.Errorf("pair %s is not configured", msg.Pair)
+	}
+	if pub := s.signerKey(msg.Signer, msg.SignedTime); pub != nil {
+		if id, err := peer.IDFromPublicKey(pub); err == nil {
+			from = id
+		}
+	}
+	return s.protocol.Validate(ctx, topicName(cfg.Pair), from, msg)
+}
+
+// verifyAt verifies the signature of a message which is not received by
+// gossip, so its author key is not known. Ed25519 messages are verified
+// with the key of the signer registry valid at the time, or with the key of
+// a connected peer with the signer address.
+func (s *Server) verifyAt(msg *protocol.ProtocolMessage, t time.Time) error {
+	return protocol.Verify(msg, s.signerKey(msg.Signer, t))
+}
+
+// signerKey returns the public key of the signer valid at the time, or nil
+// if it's not known.
+func (s *Server) signerKey(addr common.Address, t time.Time) p2pcrypto.PubKey {
+	if s.signers != nil {
+		if signer, ok := s.signers.SignerAt(addr, t); ok && signer.PublicKey != nil {
+			return signer.PublicKey
+		}
+	}
+	for _, p := range s.protocol.Host().Network().Peers() {
+		if global.PeerIDToAddress(p) != addr {
+			continue
+		}
+		if pub, err := p.ExtractPublicKey(); err == nil {
+			return pub
+		}
+	}
+	return nil
+}
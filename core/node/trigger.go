@@ -0,0 +1,68 @@
+package server
+
+import (
+	"gossip-price/core/price"
+	"math"
+	"sync"
+	"time"
+)
+
+// Trigger reasons of a round
+const (
+	// TriggerDeviation is set when the price moved more than the deviation
+	// threshold of the pair since the last price.
+	TriggerDeviation = "deviation"
+	// TriggerHeartbeat is set when the heartbeat of the pair elapsed since
+	// the last price, or when there is no last price yet.
+	TriggerHeartbeat = "heartbeat"
+	// TriggerUnknown is set when the node joins a round of a node which
+	// did not tell its trigger.
+	TriggerUnknown = "unknown"
+)
+
+// lastPrice is the last finalized price of a pair, either finalized by the
+// node or imported from a peer.
+type lastPrice struct {
+	price float64
+	time  time.Time
+}
+
+// triggers decides whether a new round of a pair is started, based on the
+// last price of the pair.
+type triggers struct {
+	mu   sync.Mutex
+	last map[string]lastPrice
+}
+
+func newTriggers() *triggers {
+	return &triggers{last: make(map[string]lastPrice)}
+}
+
+// check returns the reason to start a new round of the pair with the
+// given price, or an empty string if no round has to be started.
+func (t *triggers) check(cfg price.PairConfig, p float64, now time.Time) string {
+	t.mu.Lock()
+	last, ok := t.last[cfg.Pair.String()]
+	t.mu.Unlock()
+
+	if !ok || now.Sub(last.time) >= cfg.Heartbeat {
+		return TriggerHeartbeat
+	}
+	// A move of exactly the threshold does not start a round, the
+	// comparison is not divided so it's exact
+	if cfg.Deviation > 0 && last.price > 0 && math.Abs(p-last.price)*10000 > float64(cfg.Deviation)*last.price {
+		return TriggerDeviation
+	}
+	return ""
+}
+
+// update sets the last price of the pair, older prices are ignored.
+func (t *triggers) update(pair string, p float64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.last[pair]; ok && last.time.After(at) {
+		return
+	}
+	t.last[pair] = lastPrice{price: p, time: at}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"gossip-price/core/price"
+	"testing"
+	"time"
+)
+
+func TestTriggersCheck(t *testing.T) {
+	cfg := price.PairConfig{
+		Pair:      price.Pair{Base: "ETH", Quote: "USD"},
+		Deviation: 25,
+		Heartbeat: time.Hour,
+	}
+	last := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name  string
+		cfg   price.PairConfig
+		price float64
+		now   time.Time
+		want  string
+	}{
+		{"unchanged", cfg, 2000, last.Add(time.Minute), ""},
+		{"below threshold", cfg, 2004.99, last.Add(time.Minute), ""},
+		// The threshold is 25 basis points, 5 of 2000
+		{"at threshold", cfg, 2005, last.Add(time.Minute), ""},
+		{"at threshold down", cfg, 1995, last.Add(time.Minute), ""},
+		{"above threshold", cfg, 2005.01, last.Add(time.Minute), TriggerDeviation},
+		{"above threshold down", cfg, 1994.99, last.Add(time.Minute), TriggerDeviation},
+		{"deviation disabled", price.PairConfig{Pair: cfg.Pair, Heartbeat: time.Hour}, 3000, last.Add(time.Minute), ""},
+		{"before heartbeat", cfg, 2000, last.Add(time.Hour - time.Second), ""},
+		{"heartbeat", cfg, 2000, last.Add(time.Hour), TriggerHeartbeat},
+		// The heartbeat wins over the deviation
+		{"heartbeat and deviation", cfg, 3000, last.Add(2 * time.Hour), TriggerHeartbeat},
+	}
+	for _, tt := range tests {
+		trig := newTriggers()
+		trig.update("ETH/USD", 2000, last)
+		if got := trig.check(tt.cfg, tt.price, tt.now); got != tt.want {
+			t.Errorf("%s: check(%v) = %q, want %q", tt.name, tt.price, got, tt.want)
+		}
+	}
+
+	// Without last price, a round is started to get one
+	if got := newTriggers().check(cfg, 2000, last); got != TriggerHeartbeat {
+		t.Errorf("check() without last price = %q, want %q", got, TriggerHeartbeat)
+	}
+}
+
+func TestTriggersUpdate(t *testing.T) {
+	cfg := price.PairConfig{Pair: price.Pair{Base: "ETH", Quote: "USD"}, Deviation: 25, Heartbeat: time.Hour}
+	last := time.Unix(1700000000, 0)
+	trig := newTriggers()
+	trig.update("ETH/USD", 2000, last)
+
+	// An older price, e.g. imported late from a peer, is ignored
+	trig.update("ETH/USD", 3000, last.Add(-time.Minute))
+	if got := trig.check(cfg, 2000, last.Add(time.Minute)); got != "" {
+		t.Errorf("check() after an older update = %q, want none", got)
+	}
+	// A newer price moves the last price and restarts the heartbeat
+	trig.update("ETH/USD", 3000, last.Add(50*time.Minute))
+	if got := trig.check(cfg, 3000, last.Add(90*time.Minute)); got != "" {
+		t.Errorf("check() after a newer update = %q, want none", got)
+	}
+	// Prices of other pairs are not shared
+	if got := trig.check(price.PairConfig{Pair: price.Pair{Base: "BTC", Quote: "USD"}, Heartbeat: time.Hour}, 3000, last); got != TriggerHeartbeat {
+		t.Errorf("check() of another pair = %q, want %q", got, TriggerHeartbeat)
+	}
+}
@@ -0,0 +1,125 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gossip-price/core/metrics"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotEnoughSources is returned when fewer sources than required
+// returned a valid quote.
+var ErrNotEnoughSources = errors.New("not enough valid price sources")
+
+// Result is the aggregated price with the per-source breakdown.
+type Result struct {
+	// Pair is the aggregated pair.
+	Pair Pair
+	// Price is the median of all valid quotes.
+	Price float64
+	// Time is the time of the aggregation.
+	Time time.Time
+	// Quotes are the valid quotes used to calculate the median.
+	Quotes []Quote
+	// Failures contains the error of every source that failed or
+	// returned a stale quote, keyed by source name.
+	Failures map[string]error
+}
+
+// Aggregator fetches quotes from multiple sources concurrently and
+// returns their median.
+type Aggregator struct {
+	sources    []PriceSource
+	maxAge     time.Duration
+	minSources int
+	timeout    time.Duration
+}
+
+// NewAggregator returns a new aggregator. Quotes older than maxAge are
+// dropped, and at least minSources valid quotes are required to return
+// a price. Every source is given timeout to respond.
+func NewAggregator(sources []PriceSource, maxAge time.Duration, minSources int, timeout time.Duration) *Aggregator {
+	if minSources < 1 {
+		minSources = 1
+	}
+	return &Aggregator{
+		sources:    sources,
+		maxAge:     maxAge,
+		minSources: minSources,
+		timeout:    timeout,
+	}
+}
+
+// Fetch queries all sources for the given pair and returns the median
+// price.
+func (a *Aggregator) Fetch(ctx context.Context, pair Pair) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		now = time.Now()
+		res = &Result{Pair: pair, Time: now, Failures: make(map[string]error)}
+	)
+	for _, src := range a.sources {
+		wg.Add(1)
+		go func(src PriceSource) {
+			defer wg.Done()
+			started := time.Now()
+			quote, err := src.Fetch(ctx, pair)
+			metrics.PriceFetchLatency.WithLabelValues(src.Name()).Observe(time.Since(started).Seconds())
+			// ParseFloat accepts NaN and Inf, which cannot be signed
+			if err == nil && (math.IsNaN(quote.Price) || math.IsInf(quote.Price, 0) || quote.Price <= 0) {
+				err = fmt.Errorf("invalid price %f", quote.Price)
+			}
+			if err == nil && a.maxAge > 0 && now.Sub(quote.Time) > a.maxAge {
+				err = fmt.Errorf("stale quote from %s", quote.Time.Format(time.RFC3339))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				metrics.PriceFetchFailures.WithLabelValues(src.Name()).Inc()
+				res.Failures[src.Name()] = err
+				return
+			}
+			res.Quotes = append(res.Quotes, quote)
+		}(src)
+	}
+	wg.Wait()
+
+	if len(res.Quotes) < a.minSources {
+		return res, fmt.Errorf("%w: got %d, need %d", ErrNotEnoughSources, len(res.Quotes), a.minSources)
+	}
+	sort.Slice(res.Quotes, func(i, j int) bool {
+		return res.Quotes[i].Source < res.Quotes[j].Source
+	})
+	prices := make([]float64, 0, len(res.Quotes))
+	for _, q := range res.Quotes {
+		prices = append(prices, q.Price)
+	}
+	res.Price = Median(prices)
+	return res, nil
+}
+
+// Median returns the median of the given values, or 0 if the list is
+// empty. The given slice is not modified.
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
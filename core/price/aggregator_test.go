@@ -0,0 +1,155 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// stubSource is a PriceSource which returns a fixed quote or error.
+type stubSource struct {
+	name  string
+	price float64
+	age   time.Duration
+	err   error
+	delay time.Duration
+}
+
+func (s *stubSource) Name() string {
+	return s.name
+}
+
+func (s *stubSource) Fetch(ctx context.Context, _ Pair) (Quote, error) {
+	if s.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return Quote{}, ctx.Err()
+		case <-time.After(s.delay):
+		}
+	}
+	if s.err != nil {
+		return Quote{}, s.err
+	}
+	return Quote{Source: s.name, Price: s.price, Time: time.Now().Add(-s.age)}, nil
+}
+
+func TestAggregatorMedian(t *testing.T) {
+	agg := NewAggregator([]PriceSource{
+		&stubSource{name: "a", price: 100},
+		&stubSource{name: "b", price: 102},
+		&stubSource{name: "c", price: 110},
+		&stubSource{name: "d", price: 101},
+	}, time.Minute, 3, time.Second)
+	res, err := agg.Fetch(context.Background(), ethUSD)
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if res.Price != 101.5 {
+		t.Errorf("Fetch() price = %v, want 101.5", res.Price)
+	}
+	if len(res.Quotes) != 4 || res.Quotes[0].Source != "a" || res.Quotes[3].Source != "d" {
+		t.Errorf("Fetch() quotes = %v, want the quotes ordered by source", res.Quotes)
+	}
+}
+
+func TestAggregatorMinSources(t *testing.T) {
+	agg := NewAggregator([]PriceSource{
+		&stubSource{name: "a", price: 100},
+		&stubSource{name: "b", err: errors.New("unavailable")},
+		&stubSource{name: "c", price: -1},
+	}, time.Minute, 2, time.Second)
+	res, err := agg.Fetch(context.Background(), ethUSD)
+	if !errors.Is(err, ErrNotEnoughSources) {
+		t.Fatalf("Fetch() error = %v, want %v", err, ErrNotEnoughSources)
+	}
+	if len(res.Quotes) != 1 || len(res.Failures) != 2 {
+		t.Errorf("Fetch() = %d quotes and %d failures, want 1 and 2", len(res.Quotes), len(res.Failures))
+	}
+	if res.Failures["b"] == nil || res.Failures["c"] == nil {
+		t.Errorf("Fetch() failures = %v, want failures of b and c", res.Failures)
+	}
+}
+
+func TestAggregatorInvalidPrices(t *testing.T) {
+	tests := []struct {
+		name  string
+		price float64
+	}{
+		{"zero", 0},
+		{"negative", -1},
+		{"nan", math.NaN()},
+		{"inf", math.Inf(1)},
+		{"negative inf", math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := NewAggregator([]PriceSource{
+				&stubSource{name: "valid", price: 100},
+				&stubSource{name: "invalid", price: tt.price},
+			}, time.Minute, 1, time.Second)
+			res, err := agg.Fetch(context.Background(), ethUSD)
+			if err != nil {
+				t.Fatalf("Fetch() error: %v", err)
+			}
+			if res.Price != 100 || len(res.Quotes) != 1 || res.Failures["invalid"] == nil {
+				t.Errorf("Fetch() = %v with %d quotes, want 100 with the invalid quote as a failure", res.Price, len(res.Quotes))
+			}
+		})
+	}
+}
+
+func TestAggregatorMaxAge(t *testing.T) {
+	agg := NewAggregator([]PriceSource{
+		&stubSource{name: "fresh", price: 100},
+		&stubSource{name: "stale", price: 200, age: time.Hour},
+	}, time.Minute, 1, time.Second)
+	res, err := agg.Fetch(context.Background(), ethUSD)
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if res.Price != 100 || len(res.Quotes) != 1 {
+		t.Errorf("Fetch() = %v with %d quotes, want 100 with the fresh quote only", res.Price, len(res.Quotes))
+	}
+	if res.Failures["stale"] == nil {
+		t.Error("Fetch() did not report the stale quote as a failure")
+	}
+
+	// A zero max age accepts quotes of any age
+	agg = NewAggregator([]PriceSource{&stubSource{name: "stale", price: 200, age: time.Hour}}, 0, 1, time.Second)
+	if res, err = agg.Fetch(context.Background(), ethUSD); err != nil || res.Price != 200 {
+		t.Errorf("Fetch() = %v, %v, want 200 without max age", res.Price, err)
+	}
+}
+
+func TestAggregatorTimeout(t *testing.T) {
+	agg := NewAggregator([]PriceSource{
+		&stubSource{name: "fast", price: 100},
+		&stubSource{name: "slow", price: 200, delay: time.Second},
+	}, time.Minute, 1, 50*time.Millisecond)
+	res, err := agg.Fetch(context.Background(), ethUSD)
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if res.Price != 100 || res.Failures["slow"] == nil {
+		t.Errorf("Fetch() = %v with failures %v, want the slow source to time out", res.Price, res.Failures)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{3}, 3},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := Median(tt.values); got != tt.want {
+			t.Errorf("Median(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const binanceURL = "https://api.binance.com"
+
+type binanceResponse struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+// Binance fetches prices from the Binance ticker price endpoint. Binance
+// does not list USD pairs, so the USDT markets are used instead.
+type Binance struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewBinance returns a Binance adapter. If baseURL is empty, the public
+// API is used. If client is nil, a client with a default timeout is used.
+func NewBinance(baseURL string, client *http.Client) *Binance {
+	if baseURL == "" {
+		baseURL = binanceURL
+	}
+	return &Binance{baseURL: baseURL, client: httpClient(client)}
+}
+
+// Name implements the PriceSource interface.
+func (b *Binance) Name() string {
+	return "binance"
+}
+
+// Fetch implements the PriceSource interface.
+func (b *Binance) Fetch(ctx context.Context, pair Pair) (Quote, error) {
+	var res binanceResponse
+	err := getJSON(ctx, b.client, b.baseURL+"/api/v3/ticker/price?symbol="+url.QueryEscape(binanceSymbol(pair)), &res)
+	if err != nil {
+		return Quote{}, fmt.Errorf("binance error: %w", err)
+	}
+	price, err := strconv.ParseFloat(res.Price, 64)
+	if err != nil {
+		return Quote{}, fmt.Errorf("binance error, invalid price: %w", err)
+	}
+	return Quote{Source: b.Name(), Price: price, Time: time.Now()}, nil
+}
+
+// binanceSymbol returns the Binance symbol of the pair.
+func binanceSymbol(pair Pair) string {
+	quote := pair.Quote
+	if quote == "USD" {
+		quote = "USDT"
+	}
+	return pair.Base + quote
+}
@@ -0,0 +1,58 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bitstampURL = "https://www.bitstamp.net"
+
+type bitstampResponse struct {
+	Last      string `json:"last"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Bitstamp fetches prices from the Bitstamp ticker endpoint.
+type Bitstamp struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewBitstamp returns a Bitstamp adapter. If baseURL is empty, the public
+// API is used. If client is nil, a client with a default timeout is used.
+func NewBitstamp(baseURL string, client *http.Client) *Bitstamp {
+	if baseURL == "" {
+		baseURL = bitstampURL
+	}
+	return &Bitstamp{baseURL: baseURL, client: httpClient(client)}
+}
+
+// Name implements the PriceSource interface.
+func (b *Bitstamp) Name() string {
+	return "bitstamp"
+}
+
+// Fetch implements the PriceSource interface.
+func (b *Bitstamp) Fetch(ctx context.Context, pair Pair) (Quote, error) {
+	var res bitstampResponse
+	symbol := strings.ToLower(pair.Base + pair.Quote)
+	err := getJSON(ctx, b.client, b.baseURL+"/api/v2/ticker/"+url.PathEscape(symbol)+"/", &res)
+	if err != nil {
+		return Quote{}, fmt.Errorf("bitstamp error: %w", err)
+	}
+	price, err := strconv.ParseFloat(res.Last, 64)
+	if err != nil {
+		return Quote{}, fmt.Errorf("bitstamp error, invalid price: %w", err)
+	}
+	// Bitstamp reports the time of the ticker as unix seconds.
+	ts := time.Now()
+	if sec, err := strconv.ParseInt(res.Timestamp, 10, 64); err == nil {
+		ts = time.Unix(sec, 0)
+	}
+	return Quote{Source: b.Name(), Price: price, Time: ts}, nil
+}
@@ -0,0 +1,57 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const coinbaseURL = "https://api.coinbase.com"
+
+type coinbaseResponse struct {
+	Data struct {
+		Currency string            `json:"currency"`
+		Rates    map[string]string `json:"rates"`
+	} `json:"data"`
+}
+
+// Coinbase fetches prices from the Coinbase exchange-rates endpoint.
+type Coinbase struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewCoinbase returns a Coinbase adapter. If baseURL is empty, the public
+// API is used. If client is nil, a client with a default timeout is used.
+func NewCoinbase(baseURL string, client *http.Client) *Coinbase {
+	if baseURL == "" {
+		baseURL = coinbaseURL
+	}
+	return &Coinbase{baseURL: baseURL, client: httpClient(client)}
+}
+
+// Name implements the PriceSource interface.
+func (c *Coinbase) Name() string {
+	return "coinbase"
+}
+
+// Fetch implements the PriceSource interface.
+func (c *Coinbase) Fetch(ctx context.Context, pair Pair) (Quote, error) {
+	var res coinbaseResponse
+	err := getJSON(ctx, c.client, c.baseURL+"/v2/exchange-rates?currency="+url.QueryEscape(pair.Base), &res)
+	if err != nil {
+		return Quote{}, fmt.Errorf("coinbase error: %w", err)
+	}
+	val, ok := res.Data.Rates[pair.Quote]
+	if !ok {
+		return Quote{}, fmt.Errorf("coinbase error, no %s rate in response", pair.Quote)
+	}
+	price, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return Quote{}, fmt.Errorf("coinbase error, invalid price: %w", err)
+	}
+	return Quote{Source: c.Name(), Price: price, Time: time.Now()}, nil
+}
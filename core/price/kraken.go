@@ -0,0 +1,76 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const krakenURL = "https://api.kraken.com"
+
+type krakenResponse struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		// C is the last trade closed, as [price, lot volume].
+		C []string `json:"c"`
+	} `json:"result"`
+}
+
+// Kraken fetches prices from the Kraken public ticker endpoint.
+type Kraken struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewKraken returns a Kraken adapter. If baseURL is empty, the public
+// API is used. If client is nil, a client with a default timeout is used.
+func NewKraken(baseURL string, client *http.Client) *Kraken {
+	if baseURL == "" {
+		baseURL = krakenURL
+	}
+	return &Kraken{baseURL: baseURL, client: httpClient(client)}
+}
+
+// Name implements the PriceSource interface.
+func (k *Kraken) Name() string {
+	return "kraken"
+}
+
+// Fetch implements the PriceSource interface.
+func (k *Kraken) Fetch(ctx context.Context, pair Pair) (Quote, error) {
+	var res krakenResponse
+	err := getJSON(ctx, k.client, k.baseURL+"/0/public/Ticker?pair="+url.QueryEscape(krakenSymbol(pair)), &res)
+	if err != nil {
+		return Quote{}, fmt.Errorf("kraken error: %w", err)
+	}
+	if len(res.Error) > 0 {
+		return Quote{}, fmt.Errorf("kraken error: %s", strings.Join(res.Error, ", "))
+	}
+	// Kraken returns the pair under its own internal name (e.g. XETHZUSD),
+	// so the only entry of the result is used.
+	for _, ticker := range res.Result {
+		if len(ticker.C) == 0 {
+			break
+		}
+		price, err := strconv.ParseFloat(ticker.C[0], 64)
+		if err != nil {
+			return Quote{}, fmt.Errorf("kraken error, invalid price: %w", err)
+		}
+		return Quote{Source: k.Name(), Price: price, Time: time.Now()}, nil
+	}
+	return Quote{}, fmt.Errorf("kraken error, no ticker in response")
+}
+
+// krakenSymbol returns the Kraken symbol of the pair. Kraken uses the
+// ISO 4217-A3 code XBT for bitcoin.
+func krakenSymbol(pair Pair) string {
+	base := pair.Base
+	if base == "BTC" {
+		base = "XBT"
+	}
+	return base + pair.Quote
+}
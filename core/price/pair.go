@@ -0,0 +1,129 @@
+package price
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pair is an asset pair, e.g. ETH/USD.
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+// ParsePair parses a pair given in the BASE/QUOTE format.
+func ParsePair(s string) (Pair, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Pair{}, fmt.Errorf("price pair error, invalid pair: %q", s)
+	}
+	return Pair{
+		Base:  strings.ToUpper(parts[0]),
+		Quote: strings.ToUpper(parts[1]),
+	}, nil
+}
+
+// String returns the pair in the BASE/QUOTE format.
+func (p Pair) String() string {
+	return p.Base + "/" + p.Quote
+}
+
+// PairConfig is the per pair configuration of a node.
+type PairConfig struct {
+	Pair Pair
+	// Interval is the time between two price fetches of the pair.
+	Interval time.Duration
+	// Deviation is the price change from the last finalized price, in
+	// basis points, which starts a new round. Zero disables it.
+	Deviation int
+	// Heartbeat is the maximum time between two finalized prices. A new
+	// round is started when it elapses, even if the price did not change.
+	Heartbeat time.Duration
+	// Min and Max are the bounds of the prices accepted from the other
+	// nodes. Zero disables the bound.
+	Min float64
+	Max float64
+}
+
+// ParsePairConfigs parses a comma separated list of pairs. Every pair
+// may be followed by colon separated options in the key=value format:
+//
+//	ETH/USD,BTC/USD:interval=30:deviation=25,SOL/USD:heartbeat=600
+//
+// Supported options are:
+//   - interval: fetch interval in seconds.
+//   - deviation: deviation threshold in basis points, 0 disables it.
+//   - heartbeat: heartbeat in seconds.
+//   - min, max: bounds of the accepted prices, 0 disables them.
+//
+// Options which are not set are taken from def.
+func ParsePairConfigs(s string, def PairConfig) ([]PairConfig, error) {
+	var configs []PairConfig
+	seen := make(map[Pair]bool)
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		pair, err := ParsePair(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		if seen[pair] {
+			return nil, fmt.Errorf("price pair error, duplicated pair: %s", pair)
+		}
+		seen[pair] = true
+
+		cfg := def
+		cfg.Pair = pair
+		for _, opt := range fields[1:] {
+			key, val, ok := strings.Cut(strings.TrimSpace(opt), "=")
+			if !ok {
+				return nil, fmt.Errorf("price pair error, invalid option %q for %s", opt, pair)
+			}
+			switch key {
+			case "interval":
+				sec, err := strconv.Atoi(val)
+				if err != nil || sec <= 0 {
+					return nil, fmt.Errorf("price pair error, invalid interval %q for %s", val, pair)
+				}
+				cfg.Interval = time.Duration(sec) * time.Second
+			case "deviation":
+				bps, err := strconv.Atoi(val)
+				if err != nil || bps < 0 {
+					return nil, fmt.Errorf("price pair error, invalid deviation %q for %s", val, pair)
+				}
+				cfg.Deviation = bps
+			case "heartbeat":
+				sec, err := strconv.Atoi(val)
+				if err != nil || sec <= 0 {
+					return nil, fmt.Errorf("price pair error, invalid heartbeat %q for %s", val, pair)
+				}
+				cfg.Heartbeat = time.Duration(sec) * time.Second
+			case "min", "max":
+				bound, err := strconv.ParseFloat(val, 64)
+				if err != nil || bound < 0 || math.IsInf(bound, 0) {
+					return nil, fmt.Errorf("price pair error, invalid %s %q for %s", key, val, pair)
+				}
+				if key == "min" {
+					cfg.Min = bound
+				} else {
+					cfg.Max = bound
+				}
+			default:
+				return nil, fmt.Errorf("price pair error, unknown option %q for %s", key, pair)
+			}
+		}
+		if cfg.Max > 0 && cfg.Min > cfg.Max {
+			return nil, fmt.Errorf("price pair error, min is greater than max for %s", pair)
+		}
+		configs = append(configs, cfg)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("price pair error, no pairs configured")
+	}
+	return configs, nil
+}
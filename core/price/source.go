@@ -0,0 +1,96 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTimeout is used by the adapters when no http.Client is given.
+const defaultTimeout = 10 * time.Second
+
+// Quote is a single price observation returned by a PriceSource.
+type Quote struct {
+	// Source is the name of the source that returned the quote.
+	Source string
+	// Price is the last traded price of the pair.
+	Price float64
+	// Time is the time of the observation. Sources that do not report a
+	// timestamp use the time at which the response was received.
+	Time time.Time
+}
+
+// PriceSource is implemented by every exchange adapter. Adapters must be
+// safe for concurrent use.
+type PriceSource interface {
+	// Name returns the unique name of the source, e.g. "coinbase".
+	Name() string
+	// Fetch returns the current quote of the given pair.
+	Fetch(ctx context.Context, pair Pair) (Quote, error)
+}
+
+// NewSource returns the adapter registered under the given name, using
+// the default public endpoint of the exchange.
+func NewSource(name string) (PriceSource, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "coinbase":
+		return NewCoinbase("", nil), nil
+	case "kraken":
+		return NewKraken("", nil), nil
+	case "binance":
+		return NewBinance("", nil), nil
+	case "bitstamp":
+		return NewBitstamp("", nil), nil
+	}
+	return nil, fmt.Errorf("price source error, unknown source: %s", name)
+}
+
+// NewSources returns the adapters for a comma separated list of names.
+func NewSources(names string) ([]PriceSource, error) {
+	var sources []PriceSource
+	for _, name := range strings.Split(names, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		src, err := NewSource(name)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// getJSON sends a GET request to the url and decodes the JSON response
+// into the given value.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: defaultTimeout}
+	}
+	return client
+}
@@ -0,0 +1,149 @@
+package price
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var ethUSD = Pair{Base: "ETH", Quote: "USD"}
+
+// newTestServer returns a server which answers requests of the path and
+// query with the body, and 404 otherwise.
+func newTestServer(t *testing.T, path, query string, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path || r.URL.RawQuery != query {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSources(t *testing.T) {
+	tests := []struct {
+		name   string
+		source func(baseURL string) PriceSource
+		path   string
+		query  string
+		body   string
+		price  float64
+		time   time.Time
+	}{
+		{
+			name:   "coinbase",
+			source: func(u string) PriceSource { return NewCoinbase(u, nil) },
+			path:   "/v2/exchange-rates",
+			query:  "currency=ETH",
+			body:   `{"data":{"currency":"ETH","rates":{"USD":"2345.67","EUR":"2100.1"}}}`,
+			price:  2345.67,
+		},
+		{
+			name:   "kraken",
+			source: func(u string) PriceSource { return NewKraken(u, nil) },
+			path:   "/0/public/Ticker",
+			query:  "pair=ETHUSD",
+			body:   `{"error":[],"result":{"XETHZUSD":{"c":["2345.68","0.5"]}}}`,
+			price:  2345.68,
+		},
+		{
+			name:   "binance",
+			source: func(u string) PriceSource { return NewBinance(u, nil) },
+			path:   "/api/v3/ticker/price",
+			query:  "symbol=ETHUSDT",
+			body:   `{"symbol":"ETHUSDT","price":"2345.69000000"}`,
+			price:  2345.69,
+		},
+		{
+			name:   "bitstamp",
+			source: func(u string) PriceSource { return NewBitstamp(u, nil) },
+			path:   "/api/v2/ticker/ethusd/",
+			body:   `{"last":"2345.70","timestamp":"1700000000"}`,
+			price:  2345.70,
+			time:   time.Unix(1700000000, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.path, tt.query, http.StatusOK, tt.body)
+			src := tt.source(srv.URL)
+			if src.Name() != tt.name {
+				t.Errorf("Name() = %s, want %s", src.Name(), tt.name)
+			}
+			quote, err := src.Fetch(context.Background(), ethUSD)
+			if err != nil {
+				t.Fatalf("Fetch() error: %v", err)
+			}
+			if quote.Source != tt.name || quote.Price != tt.price {
+				t.Errorf("Fetch() = %s %v, want %s %v", quote.Source, quote.Price, tt.name, tt.price)
+			}
+			if !tt.time.IsZero() && !quote.Time.Equal(tt.time) {
+				t.Errorf("Fetch() time = %s, want %s", quote.Time, tt.time)
+			}
+			if tt.time.IsZero() && time.Since(quote.Time) > time.Minute {
+				t.Errorf("Fetch() time = %s, want the receive time", quote.Time)
+			}
+		})
+	}
+}
+
+func TestSourcesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source func(baseURL string) PriceSource
+		status int
+		body   string
+	}{
+		{"coinbase status", func(u string) PriceSource { return NewCoinbase(u, nil) }, http.StatusInternalServerError, `{}`},
+		{"coinbase missing quote", func(u string) PriceSource { return NewCoinbase(u, nil) }, http.StatusOK, `{"data":{"rates":{"EUR":"1"}}}`},
+		{"coinbase invalid price", func(u string) PriceSource { return NewCoinbase(u, nil) }, http.StatusOK, `{"data":{"rates":{"USD":"x"}}}`},
+		{"kraken error", func(u string) PriceSource { return NewKraken(u, nil) }, http.StatusOK, `{"error":["EQuery:Unknown asset pair"]}`},
+		{"kraken empty result", func(u string) PriceSource { return NewKraken(u, nil) }, http.StatusOK, `{"error":[],"result":{}}`},
+		{"binance invalid json", func(u string) PriceSource { return NewBinance(u, nil) }, http.StatusOK, `not json`},
+		{"bitstamp status", func(u string) PriceSource { return NewBitstamp(u, nil) }, http.StatusNotFound, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			if _, err := tt.source(srv.URL).Fetch(context.Background(), ethUSD); err == nil {
+				t.Error("Fetch() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	btc := Pair{Base: "BTC", Quote: "USD"}
+	if got := krakenSymbol(btc); got != "XBTUSD" {
+		t.Errorf("krakenSymbol() = %s, want XBTUSD", got)
+	}
+	if got := binanceSymbol(btc); got != "BTCUSDT" {
+		t.Errorf("binanceSymbol() = %s, want BTCUSDT", got)
+	}
+	if got := binanceSymbol(Pair{Base: "ETH", Quote: "BTC"}); got != "ETHBTC" {
+		t.Errorf("binanceSymbol() = %s, want ETHBTC", got)
+	}
+}
+
+func TestNewSources(t *testing.T) {
+	sources, err := NewSources("coinbase, kraken,,Binance,bitstamp")
+	if err != nil {
+		t.Fatalf("NewSources() error: %v", err)
+	}
+	if len(sources) != 4 {
+		t.Fatalf("NewSources() returned %d sources, want 4", len(sources))
+	}
+	if _, err := NewSources("coinbase,unknown"); err == nil {
+		t.Error("NewSources() error = nil, want an error for an unknown source")
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+/// @title Median
+/// @notice Reference oracle contract for the prices finalized by the
+/// gossip-price network. Every signer attests its price with an EIP-712
+/// PriceAttestation signature, and the contract stores the median of at
+/// least `bar` attestations from authorized signers.
+/// @dev poke needs more stack slots than the legacy code generator has, so
+/// the contract is compiled with the IR pipeline. Median.bin is the output
+/// of solc 0.8.21 with `--via-ir --optimize --optimize-runs 200
+/// --evm-version paris`, which leaves out PUSH0 for the chains before Shanghai.
+contract Median {
+    bytes32 public constant DOMAIN_TYPEHASH =
+        keccak256("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)");
+    bytes32 public constant ATTESTATION_TYPEHASH = keccak256(
+        "PriceAttestation(uint8 version,string pair,string msgId,uint256 price,uint256 timestamp,address signer)"
+    );
+
+    struct Rate {
+        uint256 price;
+        uint256 timestamp;
+        string msgId;
+    }
+
+    address public owner;
+    uint256 public bar;
+    bytes32 public immutable DOMAIN_SEPARATOR;
+
+    mapping(address => bool) public orcl;
+    mapping(bytes32 => Rate) public rates;
+    address[] private signers;
+
+    event LogMedianPrice(string pair, string msgId, uint256 price, uint256 timestamp);
+
+    modifier onlyOwner() {
+        require(msg.sender == owner, "Median/not-owner");
+        _;
+    }
+
+    constructor(string memory name, string memory version, uint256 bar_) {
+        owner = msg.sender;
+        bar = bar_;
+        DOMAIN_SEPARATOR = keccak256(
+            abi.encode(DOMAIN_TYPEHASH, keccak256(bytes(name)), keccak256(bytes(version)), block.chainid, address(this))
+        );
+    }
+
+    function lift(address[] calldata accounts) external onlyOwner {
+        for (uint256 i = 0; i < accounts.length; i++) {
+            if (!orcl[accounts[i]]) {
+                orcl[accounts[i]] = true;
+                signers.push(accounts[i]);
+            }
+        }
+    }
+
+    function drop(address[] calldata accounts) external onlyOwner {
+        for (uint256 i = 0; i < accounts.length; i++) {
+            if (!orcl[accounts[i]]) {
+                continue;
+            }
+            orcl[accounts[i]] = false;
+            for (uint256 j = 0; j < signers.length; j++) {
+                if (signers[j] == accounts[i]) {
+                    signers[j] = signers[signers.length - 1];
+                    signers.pop();
+                    break;
+                }
+            }
+        }
+    }
+
+    /// @notice Returns the authorized signers, used by the nodes as their
+    /// signer registry.
+    function getSigners() external view returns (address[] memory) {
+        return signers;
+    }
+
+    function setBar(uint256 bar_) external onlyOwner {
+        require(bar_ > 0, "Median/invalid-bar");
+        bar = bar_;
+    }
+
+    function read(string calldata pair) external view returns (uint256 price, uint256 timestamp) {
+        Rate storage rate = rates[keccak256(bytes(pair))];
+        require(rate.price > 0, "Median/invalid-price");
+        return (rate.price, rate.timestamp);
+    }
+
+    /// @notice Submits the attestations of a finalized rate. The arrays
+    /// must be of the same length, ordered by ascending price, and every
+    /// signer may occur only once.
+    function poke(
+        string calldata pair,
+        string calldata msgId,
+        uint256[] calldata prices,
+        uint256[] calldata timestamps,
+        address[] calldata signers_,
+        uint8[] calldata v,
+        bytes32[] calldata r,
+        bytes32[] calldata s
+    ) external {
+        uint256 n = prices.length;
+        require(n >= bar, "Median/bar-too-low");
+        require(
+            timestamps.length == n && signers_.length == n && v.length == n && r.length == n && s.length == n,
+            "Median/invalid-length"
+        );
+
+        bytes32 pairHash = keccak256(bytes(pair));
+        bytes32 msgIdHash = keccak256(bytes(msgId));
+        uint256 latest = 0;
+        for (uint256 i = 0; i < n; i++) {
+            require(orcl[signers_[i]], "Median/invalid-oracle");
+            require(i == 0 || prices[i - 1] <= prices[i], "Median/messages-not-in-order");
+            for (uint256 j = 0; j < i; j++) {
+                require(signers_[j] != signers_[i], "Median/duplicated-signer");
+            }
+            bytes32 digest = _digest(pairHash, msgIdHash, prices[i], timestamps[i], signers_[i]);
+            require(ecrecover(digest, v[i], r[i], s[i]) == signers_[i], "Median/invalid-signature");
+            if (timestamps[i] > latest) {
+                latest = timestamps[i];
+            }
+        }
+
+        Rate storage rate = rates[pairHash];
+        require(latest > rate.timestamp, "Median/stale-message");
+
+        uint256 price = prices[n / 2];
+        if (n % 2 == 0) {
+            price = (prices[n / 2 - 1] + prices[n / 2]) / 2;
+        }
+        rate.price = price;
+        rate.timestamp = latest;
+        rate.msgId = msgId;
+        emit LogMedianPrice(pair, msgId, price, latest);
+    }
+
+    function _digest(bytes32 pairHash, bytes32 msgIdHash, uint256 price, uint256 timestamp, address signer)
+        internal
+        view
+        returns (bytes32)
+    {
+        bytes32 structHash =
+            keccak256(abi.encode(ATTESTATION_TYPEHASH, uint8(1), pairHash, msgIdHash, price, timestamp, signer));
+        return keccak256(abi.encodePacked("\x19\x01", DOMAIN_SEPARATOR, structHash));
+    }
+}
@@ -0,0 +1,187 @@
+package publisher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gossip-price/core/consensus"
+	protocol "gossip-price/core/gossip"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MedianABI is the ABI of the reference Median oracle contract, see
+// abi/Median.sol.
+//
+//go:embed abi/Median.abi.json
+var MedianABI string
+
+// queueSize is the number of finalized rates waiting to be published.
+const queueSize = 64
+
+// ErrNoSignatures is returned when a rate has no EIP-712 signatures that
+// can be verified by the contract.
+var ErrNoSignatures = errors.New("no EIP-712 signatures to publish")
+
+// Backend is the subset of the Ethereum client used by the publisher. It
+// is implemented by ethclient.Client and by the simulated backend of
+// go-ethereum.
+type Backend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Config is the configuration of the Publisher.
+type Config struct {
+	// Contract is the address of the Median oracle contract.
+	Contract common.Address
+	// Key is the private key of the account that sends the transactions.
+	Key *ecdsa.PrivateKey
+	// ReceiptTimeout is the time to wait for a transaction to be mined
+	// before it is replaced with a higher gas price.
+	ReceiptTimeout time.Duration
+	// Retries is the number of times a transaction is replaced or resent
+	// before the rate is dropped.
+	Retries int
+}
+
+// Publisher submits finalized rates to the Median oracle contract.
+type Publisher struct {
+	backend  Backend
+	abi      abi.ABI
+	contract common.Address
+	key      *ecdsa.PrivateKey
+	from     common.Address
+	timeout  time.Duration
+	retries  int
+	queue    chan consensus.FinalizedRate
+
+	mu      sync.Mutex
+	chainID *big.Int
+	nonce   *uint64
+}
+
+// New returns a new publisher.
+func New(backend Backend, c Config) (*Publisher, error) {
+	if c.Key == nil {
+		return nil, errors.New("publisher error, private key is not set")
+	}
+	parsed, err := abi.JSON(strings.NewReader(MedianABI))
+	if err != nil {
+		return nil, fmt.Errorf("publisher error, unable to parse ABI: %w", err)
+	}
+	if c.ReceiptTimeout <= 0 {
+		c.ReceiptTimeout = time.Minute
+	}
+	return &Publisher{
+		backend:  backend,
+		abi:      parsed,
+		contract: c.Contract,
+		key:      c.Key,
+		from:     crypto.PubkeyToAddress(c.Key.PublicKey),
+		timeout:  c.ReceiptTimeout,
+		retries:  c.Retries,
+		queue:    make(chan consensus.FinalizedRate, queueSize),
+	}, nil
+}
+
+// Start publishes the enqueued rates until the context is canceled.
+func (p *Publisher) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rate := <-p.queue:
+				tx, err := p.Publish(ctx, rate)
+				if err != nil {
+					log.Printf("Publishing message(%s) failed: %v", rate.MsgId, err)
+					continue
+				}
+				log.Printf("Message(%s) is published in transaction %s", rate.MsgId, tx.Hex())
+			}
+		}
+	}()
+}
+
+// Enqueue adds the rate to the publishing queue. It implements the
+// consensus.FinalizedHandler function, and drops the rate if the queue
+// is full.
+func (p *Publisher) Enqueue(rate consensus.FinalizedRate) {
+	select {
+	case p.queue <- rate:
+	default:
+		log.Printf("Publisher queue is full, message(%s) is dropped", rate.MsgId)
+	}
+}
+
+// Publish sends the poke transaction of the rate and waits until it is
+// mined. It returns the hash of the mined transaction.
+func (p *Publisher) Publish(ctx context.Context, rate consensus.FinalizedRate) (common.Hash, error) {
+	data, err := p.PackPoke(rate)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return p.send(ctx, data)
+}
+
+// PackPoke returns the call data of the poke function for the rate. Only
+// the EIP-712 signatures are included, ordered by price as required by
+// the contract.
+func (p *Publisher) PackPoke(rate consensus.FinalizedRate) ([]byte, error) {
+	var msgs []protocol.ProtocolMessage
+	seen := make(map[common.Address]bool)
+	for _, msg := range rate.Messages {
+		if msg.Scheme != protocol.SchemeEIP712 || len(msg.Signature) != crypto.SignatureLength {
+			continue
+		}
+		if seen[msg.Signer] {
+			continue
+		}
+		seen[msg.Signer] = true
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		return nil, ErrNoSignatures
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].Price < msgs[j].Price
+	})
+
+	var (
+		prices     = make([]*big.Int, len(msgs))
+		timestamps = make([]*big.Int, len(msgs))
+		signers    = make([]common.Address, len(msgs))
+		v          = make([]uint8, len(msgs))
+		r          = make([][32]byte, len(msgs))
+		s          = make([][32]byte, len(msgs))
+	)
+	for i, msg := range msgs {
+		price, err := protocol.PriceToWei(msg.Price)
+		if err != nil {
+			return nil, fmt.Errorf("publisher error, invalid price of %s: %w", msg.Signer, err)
+		}
+		prices[i] = price
+		timestamps[i] = big.NewInt(msg.SignedTime.Unix())
+		signers[i] = msg.Signer
+		copy(r[i][:], msg.Signature[:32])
+		copy(s[i][:], msg.Signature[32:64])
+		v[i] = msg.Signature[64]
+	}
+	return p.abi.Pack("poke", rate.Pair, rate.MsgId, prices, timestamps, signers, v, r, s)
+}
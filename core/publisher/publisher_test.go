@@ -0,0 +1,313 @@
+package publisher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/consensus"
+	protocol "gossip-price/core/gossip"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simulatedChainID is the chain ID of the simulated backend.
+var simulatedChainID = big.NewInt(1337)
+
+// autoCommit mines a block after every transaction sent, unless hold
+// returns true for the transaction, which then stays in the pool.
+type autoCommit struct {
+	*backends.SimulatedBackend
+
+	mu   sync.Mutex
+	sent []*types.Transaction
+	hold func(n int, tx *types.Transaction) bool
+}
+
+func (b *autoCommit) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.sent = append(b.sent, tx)
+	held := b.hold != nil && b.hold(len(b.sent), tx)
+	b.mu.Unlock()
+	if !held {
+		b.Commit()
+	}
+	return nil
+}
+
+// simulation is a Median contract deployed on a simulated chain, with
+// three authorized signers.
+type simulation struct {
+	backend  *autoCommit
+	contract *bind.BoundContract
+	address  common.Address
+	owner    *ecdsa.PrivateKey
+	signers  []*protocol.LocalSigner
+}
+
+func newSimulation(t *testing.T) *simulation {
+	t.Helper()
+	owner, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(owner.PublicKey): {Balance: new(big.Int).Lsh(big.NewInt(1), 100)},
+	}, 30_000_000)
+	t.Cleanup(func() { _ = sim.Close() })
+
+	bin, err := os.ReadFile("abi/Median.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(MedianABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(owner, simulatedChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := protocol.DefaultDomain
+	address, _, contract, err := bind.DeployContract(auth, parsed, common.FromHex(strings.TrimSpace(string(bin))), sim, domain.Name, domain.Version, big.NewInt(3))
+	if err != nil {
+		t.Fatalf("deploying Median failed: %v", err)
+	}
+	sim.Commit()
+
+	s := &simulation{
+		backend:  &autoCommit{SimulatedBackend: sim},
+		contract: contract,
+		address:  address,
+		owner:    owner,
+	}
+	var addrs []common.Address
+	for i := 0; i < 3; i++ {
+		key, _, err := p2pcrypto.GenerateSecp256k1Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := protocol.NewLocalSigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.signers = append(s.signers, signer)
+		addrs = append(addrs, signer.Address())
+	}
+	if _, err = contract.Transact(auth, "lift", addrs); err != nil {
+		t.Fatalf("lift failed: %v", err)
+	}
+	sim.Commit()
+
+	// The contract verifies the signatures with its own domain
+	prev := protocol.DefaultDomain
+	protocol.DefaultDomain.ChainID = simulatedChainID
+	protocol.DefaultDomain.VerifyingContract = address
+	t.Cleanup(func() { protocol.DefaultDomain = prev })
+	return s
+}
+
+// finalize returns the rate finalized with the observations of the
+// signers.
+func (s *simulation) finalize(t *testing.T, msgID string, prices ...float64) consensus.FinalizedRate {
+	t.Helper()
+	rate := consensus.FinalizedRate{MsgId: msgID, Pair: "ETH/USD"}
+	for i, price := range prices {
+		msg := &protocol.ProtocolMessage{MsgId: msgID, Pair: rate.Pair, Price: price}
+		if _, err := msg.Sign(context.Background(), s.signers[i]); err != nil {
+			t.Fatal(err)
+		}
+		rate.Messages = append(rate.Messages, *msg)
+	}
+	rate.Price = prices[len(prices)/2]
+	return rate
+}
+
+func (s *simulation) publisher(t *testing.T, timeout time.Duration, retries int) *Publisher {
+	t.Helper()
+	pub, err := New(s.backend, Config{
+		Contract:       s.address,
+		Key:            s.owner,
+		ReceiptTimeout: timeout,
+		Retries:        retries,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+// read returns the price and the timestamp stored by the contract.
+func (s *simulation) read(t *testing.T, pair string) (*big.Int, *big.Int) {
+	t.Helper()
+	var out []any
+	if err := s.contract.Call(&bind.CallOpts{}, &out, "read", pair); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return out[0].(*big.Int), out[1].(*big.Int)
+}
+
+func TestPublish(t *testing.T) {
+	s := newSimulation(t)
+	pub := s.publisher(t, 5*time.Second, 0)
+
+	// The messages are not ordered by price, the publisher orders them
+	rate := s.finalize(t, "ETH/USD-1", 2001.5, 2000.25, 2003)
+	hash, err := pub.Publish(context.Background(), rate)
+	if err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	receipt, err := s.backend.TransactionReceipt(context.Background(), hash)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("poke transaction %s is not successful: %v", hash.Hex(), err)
+	}
+
+	price, timestamp := s.read(t, "ETH/USD")
+	if want := wei(t, 2001.5); price.Cmp(want) != 0 {
+		t.Errorf("read() price = %s, want %s", price, want)
+	}
+	var latest int64
+	for _, msg := range rate.Messages {
+		if msg.SignedTime.Unix() > latest {
+			latest = msg.SignedTime.Unix()
+		}
+	}
+	if timestamp.Int64() != latest {
+		t.Errorf("read() timestamp = %s, want %d", timestamp, latest)
+	}
+}
+
+func TestPublishRevertsWithOtherDomain(t *testing.T) {
+	s := newSimulation(t)
+	pub := s.publisher(t, 5*time.Second, 0)
+
+	// Messages signed for another verifying contract are rejected by the
+	// signature recovery of the contract
+	protocol.DefaultDomain.VerifyingContract = common.Address{}
+	rate := s.finalize(t, "ETH/USD-1", 2000, 2001, 2002)
+	if _, err := pub.Publish(context.Background(), rate); err == nil || !strings.Contains(err.Error(), "Median/invalid-signature") {
+		t.Fatalf("Publish() error = %v, want Median/invalid-signature", err)
+	}
+}
+
+func TestPublishBumpsGasPrice(t *testing.T) {
+	s := newSimulation(t)
+	// The first transaction is never mined, so it must be replaced
+	s.backend.hold = func(n int, _ *types.Transaction) bool { return n == 1 }
+	pub := s.publisher(t, 500*time.Millisecond, 2)
+
+	hash, err := pub.Publish(context.Background(), s.finalize(t, "ETH/USD-1", 2000, 2001, 2002))
+	if err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if len(s.backend.sent) != 2 {
+		t.Fatalf("sent %d transactions, want 2", len(s.backend.sent))
+	}
+	first, replacement := s.backend.sent[0], s.backend.sent[1]
+	if hash != replacement.Hash() {
+		t.Errorf("Publish() = %s, want the replacement %s", hash.Hex(), replacement.Hash().Hex())
+	}
+	if replacement.Nonce() != first.Nonce() {
+		t.Errorf("replacement nonce = %d, want %d", replacement.Nonce(), first.Nonce())
+	}
+	if want := bumpGasPrice(first.GasPrice()); replacement.GasPrice().Cmp(want) != 0 {
+		t.Errorf("replacement gas price = %s, want %s", replacement.GasPrice(), want)
+	}
+	if _, err := s.backend.TransactionReceipt(context.Background(), first.Hash()); err == nil {
+		t.Error("the replaced transaction was mined")
+	}
+	if price, _ := s.read(t, "ETH/USD"); price.Cmp(wei(t, 2001)) != 0 {
+		t.Errorf("read() price = %s, want %s", price, wei(t, 2001))
+	}
+
+	// The nonce is advanced past the replaced transaction. The contract
+	// only accepts messages signed after the stored ones, so the next rate
+	// is signed in the next second
+	s.backend.hold = nil
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	if _, err = pub.Publish(context.Background(), s.finalize(t, "ETH/USD-2", 2010, 2011, 2012)); err != nil {
+		t.Fatalf("Publish() of the next rate error: %v", err)
+	}
+}
+
+func TestPublishGivesUp(t *testing.T) {
+	s := newSimulation(t)
+	s.backend.hold = func(int, *types.Transaction) bool { return true }
+	pub := s.publisher(t, 200*time.Millisecond, 1)
+
+	_, err := pub.Publish(context.Background(), s.finalize(t, "ETH/USD-1", 2000, 2001, 2002))
+	if err == nil || !strings.Contains(err.Error(), "not mined after 2 attempts") {
+		t.Fatalf("Publish() error = %v, want the transaction not to be mined", err)
+	}
+	if len(s.backend.sent) != 2 || s.backend.sent[1].GasPrice().Cmp(s.backend.sent[0].GasPrice()) <= 0 {
+		t.Errorf("sent %d transactions, want 2 with an increasing gas price", len(s.backend.sent))
+	}
+}
+
+func TestPackPokeSkipsEd25519(t *testing.T) {
+	pub, err := New(nil, Config{Key: mustKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, _ := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	signer, _ := protocol.NewLocalSigner(key)
+	msg := protocol.ProtocolMessage{MsgId: "ETH/USD-1", Pair: "ETH/USD", Price: 2000}
+	if _, err := msg.Sign(context.Background(), signer); err != nil {
+		t.Fatal(err)
+	}
+	_, err = pub.PackPoke(consensus.FinalizedRate{MsgId: msg.MsgId, Pair: msg.Pair, Messages: []protocol.ProtocolMessage{msg}})
+	if !errors.Is(err, ErrNoSignatures) {
+		t.Errorf("PackPoke() error = %v, want %v", err, ErrNoSignatures)
+	}
+}
+
+func TestBumpGasPrice(t *testing.T) {
+	tests := []struct {
+		price int64
+		want  int64
+	}{
+		{100, 120},
+		{1_000_000_000, 1_200_000_000},
+		// Small prices are increased by at least one
+		{1, 2},
+		{0, 1},
+	}
+	for _, tt := range tests {
+		if got := bumpGasPrice(big.NewInt(tt.price)); got.Int64() != tt.want {
+			t.Errorf("bumpGasPrice(%d) = %s, want %d", tt.price, got, tt.want)
+		}
+	}
+}
+
+func wei(t *testing.T, price float64) *big.Int {
+	t.Helper()
+	w, err := protocol.PriceToWei(price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func mustKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
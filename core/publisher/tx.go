@@ -0,0 +1,160 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// receiptPollInterval is the interval of the transaction receipt checks.
+const receiptPollInterval = time.Second
+
+// gasPriceBump is the percentage by which the gas price is increased when
+// a transaction is replaced. Nodes require at least 10%.
+const gasPriceBump = 20
+
+// send signs and sends a transaction with the given call data to the
+// contract. If the transaction is not mined within the receipt timeout,
+// it is replaced by a transaction with the same nonce and a higher gas
+// price.
+func (p *Publisher) send(ctx context.Context, data []byte) (common.Hash, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chainID, err := p.chainIDLocked(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	nonce, err := p.nonceLocked(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gas, err := p.backend.EstimateGas(ctx, ethereum.CallMsg{
+		From: p.from,
+		To:   &p.contract,
+		Data: data,
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("publisher error, unable to estimate gas: %w", err)
+	}
+	gasPrice, err := p.backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("publisher error, unable to get gas price: %w", err)
+	}
+
+	var sent []common.Hash
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		if attempt > 0 {
+			gasPrice = bumpGasPrice(gasPrice)
+		}
+		tx, err := types.SignNewTx(p.key, types.NewEIP155Signer(chainID), &types.LegacyTx{
+			Nonce:    nonce,
+			To:       &p.contract,
+			Gas:      gas,
+			GasPrice: gasPrice,
+			Data:     data,
+		})
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("publisher error, unable to sign transaction: %w", err)
+		}
+		err = p.backend.SendTransaction(ctx, tx)
+		switch {
+		case err == nil, isError(err, "already known"):
+			sent = append(sent, tx.Hash())
+		case isError(err, "nonce too low"):
+			// Another transaction of the account was mined with this
+			// nonce, so the nonce is fetched again.
+			p.nonce = nil
+			if nonce, err = p.nonceLocked(ctx); err != nil {
+				return common.Hash{}, err
+			}
+			continue
+		case isError(err, "replacement transaction underpriced"):
+			continue
+		default:
+			return common.Hash{}, fmt.Errorf("publisher error, unable to send transaction: %w", err)
+		}
+
+		receipt, err := p.waitReceipt(ctx, sent)
+		if err == nil {
+			next := nonce + 1
+			p.nonce = &next
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return receipt.TxHash, fmt.Errorf("publisher error, transaction %s reverted", receipt.TxHash.Hex())
+			}
+			return receipt.TxHash, nil
+		}
+		if ctx.Err() != nil {
+			return common.Hash{}, ctx.Err()
+		}
+	}
+	// The nonce may still be used by one of the pending transactions, so
+	// it is fetched again before the next transaction.
+	p.nonce = nil
+	return common.Hash{}, fmt.Errorf("publisher error, transaction not mined after %d attempts", p.retries+1)
+}
+
+// waitReceipt waits until one of the given transactions is mined or the
+// receipt timeout is reached.
+func (p *Publisher) waitReceipt(ctx context.Context, hashes []common.Hash) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	for {
+		for _, hash := range hashes {
+			receipt, err := p.backend.TransactionReceipt(ctx, hash)
+			if err == nil && receipt != nil {
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(receiptPollInterval):
+		}
+	}
+}
+
+func (p *Publisher) chainIDLocked(ctx context.Context) (*big.Int, error) {
+	if p.chainID != nil {
+		return p.chainID, nil
+	}
+	chainID, err := p.backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("publisher error, unable to get chain ID: %w", err)
+	}
+	p.chainID = chainID
+	return chainID, nil
+}
+
+func (p *Publisher) nonceLocked(ctx context.Context) (uint64, error) {
+	if p.nonce != nil {
+		return *p.nonce, nil
+	}
+	nonce, err := p.backend.PendingNonceAt(ctx, p.from)
+	if err != nil {
+		return 0, fmt.Errorf("publisher error, unable to get nonce: %w", err)
+	}
+	p.nonce = &nonce
+	return nonce, nil
+}
+
+// bumpGasPrice returns the gas price increased by gasPriceBump percent.
+func bumpGasPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+gasPriceBump))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(price) <= 0 {
+		bumped.Add(price, big.NewInt(1))
+	}
+	return bumped
+}
+
+// isError reports whether the error returned by the node contains the
+// given message. Nodes return these errors as plain strings.
+func isError(err error, msg string) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), msg)
+}
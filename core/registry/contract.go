@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// committeeABI is the ABI of the getSigners function of the committee
+// contract. It is implemented by the reference Median contract.
+const committeeABI = `[{"type":"function","name":"getSigners","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address[]"}]}]`
+
+// ContractSource loads the signer addresses from the getSigners function
+// of a contract. Contracts only know the addresses, so the signers of a
+// contract must use the EIP-712 scheme.
+type ContractSource struct {
+	caller   ethereum.ContractCaller
+	contract common.Address
+	abi      abi.ABI
+}
+
+// NewContractSource returns a source which loads the signers from the
+// contract.
+func NewContractSource(caller ethereum.ContractCaller, contract common.Address) (*ContractSource, error) {
+	parsed, err := abi.JSON(strings.NewReader(committeeABI))
+	if err != nil {
+		return nil, fmt.Errorf("registry error, invalid committee abi: %w", err)
+	}
+	return &ContractSource{
+		caller:   caller,
+		contract: contract,
+		abi:      parsed,
+	}, nil
+}
+
+// Name implements the Source interface.
+func (c *ContractSource) Name() string {
+	return "contract " + c.contract.Hex()
+}
+
+// Load implements the Source interface.
+func (c *ContractSource) Load(ctx context.Context) ([]Signer, error) {
+	data, err := c.abi.Pack("getSigners")
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.caller.CallContract(ctx, ethereum.CallMsg{To: &c.contract, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.abi.Unpack("getSigners", out)
+	if err != nil {
+		return nil, fmt.Errorf("invalid getSigners result: %w", err)
+	}
+	addrs, ok := res[0].([]common.Address)
+	if !ok {
+		return nil, fmt.Errorf("invalid getSigners result type %T", res[0])
+	}
+	signers := make([]Signer, 0, len(addrs))
+	for _, addr := range addrs {
+		signers = append(signers, Signer{Address: addr})
+	}
+	return signers, nil
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	"os"
+	"time"
+)
+
+// FileSource loads the signers from a JSON file:
+//
+//	{
+//	  "signers": [
+//	    {"name": "node-1", "peer_id": "12D3KooW..."},
+//	    {"name": "node-2", "public_key": "CAISIQ..."},
+//	    {"name": "node-3", "address": "0x..."},
+//	    {"name": "node-3", "address": "0x...", "activation": 1767225600, "expiry": 1798761600}
+//	  ]
+//	}
+//
+// The activation and expiry are unix timestamps bounding the validity of
+// the key, both are optional. The public key is the base64 encoded libp2p
+// public key. The address is
+// derived from the peer ID or the public key if it's not set, otherwise it
+// must match them. Signers with only an address are accepted, but their
+// messages can only be verified with the EIP-712 scheme.
+type FileSource struct {
+	path string
+}
+
+type signerFile struct {
+	Signers []signerEntry `json:"signers"`
+}
+
+type signerEntry struct {
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	PeerID    string `json:"peer_id"`
+	PublicKey string `json:"public_key"`
+	// Activation and Expiry are unix timestamps, zero if not bounded
+	Activation int64 `json:"activation"`
+	Expiry     int64 `json:"expiry"`
+}
+
+// NewFileSource returns a source which loads the signers from the file.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Name implements the Source interface.
+func (f *FileSource) Name() string {
+	return "file " + f.path
+}
+
+// Load implements the Source interface. The file is read again on every
+// call, so changes are picked up by the next reload.
+func (f *FileSource) Load(_ context.Context) ([]Signer, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var file signerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid signer file: %w", err)
+	}
+	signers := make([]Signer, 0, len(file.Signers))
+	for i, e := range file.Signers {
+		s, err := e.signer()
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer #%d: %w", i, err)
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+func (e signerEntry) signer() (Signer, error) {
+	s := Signer{Name: e.Name}
+	switch {
+	case e.PublicKey != "":
+		raw, err := crypto.ConfigDecodeKey(e.PublicKey)
+		if err != nil {
+			return s, err
+		}
+		if s.PublicKey, err = crypto.UnmarshalPublicKey(raw); err != nil {
+			return s, err
+		}
+	case e.PeerID != "":
+		id, err := peer.Decode(e.PeerID)
+		if err != nil {
+			return s, err
+		}
+		if s.PublicKey, err = id.ExtractPublicKey(); err != nil {
+			return s, err
+		}
+	}
+	if s.PublicKey != nil {
+		id, err := peer.IDFromPublicKey(s.PublicKey)
+		if err != nil {
+			return s, err
+		}
+		s.Address = global.PeerIDToAddress(id)
+	}
+	if e.Address != "" {
+		if !common.IsHexAddress(e.Address) {
+			return s, fmt.Errorf("invalid address %q", e.Address)
+		}
+		addr := common.HexToAddress(e.Address)
+		if s.PublicKey != nil && addr != s.Address {
+			return s, global.ErrSignerMismatch
+		}
+		s.Address = addr
+	}
+	if s.Address == (common.Address{}) {
+		return s, fmt.Errorf("one of address, peer_id or public_key is required")
+	}
+	if e.Activation > 0 {
+		s.ActivatesAt = time.Unix(e.Activation, 0)
+	}
+	if e.Expiry > 0 {
+		s.ExpiresAt = time.Unix(e.Expiry, 0)
+	}
+	if e.Activation > 0 && e.Expiry > 0 && e.Expiry <= e.Activation {
+		return s, fmt.Errorf("expiry %d is not after activation %d", e.Expiry, e.Activation)
+	}
+	return s, nil
+}
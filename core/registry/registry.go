@@ -0,0 +1,211 @@
+// Package registry contains the signer registry, the committee of signers
+// whose signatures count toward the quorum of a message.
+//
+// Every key of a signer is a separate entry with its own validity window, so
+// a signer can rotate its key: the new key is activated before the old one
+// expires, and the node switches to it anytime within the overlap. Entries
+// of the same signer share its name, and count once toward the quorum.
+package registry
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/metrics"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Signer is an authorized signer of the committee.
+type Signer struct {
+	// Name is an optional human readable name of the signer.
+	Name string
+	// Address is the signer address of the messages.
+	Address common.Address
+	// PublicKey is the public key of the signer. It is nil when the source
+	// only knows the address, e.g. the EIP-712 signers of a contract.
+	PublicKey crypto.PubKey
+	// ActivatesAt is the time the key becomes valid, zero if it's valid
+	// since ever.
+	ActivatesAt time.Time
+	// ExpiresAt is the time the key stops being valid, zero if it never
+	// expires.
+	ExpiresAt time.Time
+}
+
+// ValidAt returns true if the key of the signer is valid at the time.
+func (s Signer) ValidAt(t time.Time) bool {
+	if !s.ActivatesAt.IsZero() && t.Before(s.ActivatesAt) {
+		return false
+	}
+	return s.ExpiresAt.IsZero() || t.Before(s.ExpiresAt)
+}
+
+// Identity returns the identity of the signer, shared by all of its keys.
+// It's the name of the signer, or its address if it has no name.
+func (s Signer) Identity() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Address.Hex()
+}
+
+// Source loads the signers of the registry.
+type Source interface {
+	// Name returns the name of the source used in logs.
+	Name() string
+	// Load returns the current signers of the source.
+	Load(ctx context.Context) ([]Signer, error)
+}
+
+// Registry is the set of authorized signers, loaded from one or more
+// sources. Signers of all sources are authorized.
+type Registry struct {
+	mu      sync.RWMutex
+	sources []Source
+	signers map[common.Address]Signer
+}
+
+// New returns a new registry of the given sources. The registry is empty
+// until it is loaded with Reload.
+func New(sources ...Source) *Registry {
+	return &Registry{
+		sources: sources,
+		signers: make(map[common.Address]Signer),
+	}
+}
+
+// Reload loads the signers of all sources and replaces the current
+// signers. If any of the sources fails, the current signers are kept.
+func (r *Registry) Reload(ctx context.Context) error {
+	signers := make(map[common.Address]Signer)
+	for _, src := range r.sources {
+		list, err := src.Load(ctx)
+		if err != nil {
+			metrics.RegistryReloadErrors.Inc()
+			return fmt.Errorf("registry error, unable to load %s: %w", src.Name(), err)
+		}
+		for _, s := range list {
+			// A source which knows the public key wins over the ones which
+			// only know the address
+			if prev, ok := signers[s.Address]; ok && s.PublicKey == nil {
+				s.PublicKey = prev.PublicKey
+			}
+			if s.Name == "" {
+				s.Name = signers[s.Address].Name
+			}
+			if s.ActivatesAt.IsZero() && s.ExpiresAt.IsZero() {
+				s.ActivatesAt = signers[s.Address].ActivatesAt
+				s.ExpiresAt = signers[s.Address].ExpiresAt
+			}
+			signers[s.Address] = s
+		}
+	}
+
+	r.mu.Lock()
+	changed := !sameSigners(r.signers, signers)
+	r.signers = signers
+	r.mu.Unlock()
+
+	valid := countValid(signers, time.Now())
+	metrics.RegistrySigners.Set(float64(valid))
+	if changed {
+		log.Printf("Signer registry updated, %d signer keys, %d valid now", len(signers), valid)
+	}
+	return nil
+}
+
+// Start reloads the registry on every interval until the context is
+// canceled. Failed reloads are logged and the previous signers are kept.
+func (r *Registry) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+				if err := r.Reload(ctx); err != nil {
+					log.Printf("Reloading signer registry failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// IsAuthorized returns true if the address is an authorized signer whose
+// key is valid now.
+func (r *Registry) IsAuthorized(addr common.Address) bool {
+	return r.IsAuthorizedAt(addr, time.Now())
+}
+
+// IsAuthorizedAt returns true if the address is an authorized signer whose
+// key is valid at the time.
+func (r *Registry) IsAuthorizedAt(addr common.Address, t time.Time) bool {
+	_, ok := r.SignerAt(addr, t)
+	return ok
+}
+
+// Signer returns the signer of the address, whether its key is valid or not.
+func (r *Registry) Signer(addr common.Address) (Signer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.signers[addr]
+	return s, ok
+}
+
+// SignerAt returns the signer of the address if its key is valid at the
+// time. Historic messages are verified with the time they were signed at,
+// so they stay valid after the key is rotated.
+func (r *Registry) SignerAt(addr common.Address, t time.Time) (Signer, bool) {
+	s, ok := r.Signer(addr)
+	if !ok || !s.ValidAt(t) {
+		return Signer{}, false
+	}
+	return s, true
+}
+
+// Signers returns all signers of the registry, including the ones whose
+// key is not valid now, ordered by address.
+func (r *Registry) Signers() []Signer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	signers := make([]Signer, 0, len(r.signers))
+	for _, s := range r.signers {
+		signers = append(signers, s)
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return signers[i].Address.Hex() < signers[j].Address.Hex()
+	})
+	return signers
+}
+
+// sameSigners returns true if both sets contain the same addresses with
+// the same validity windows.
+func sameSigners(a, b map[common.Address]Signer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for addr, s := range a {
+		other, ok := b[addr]
+		if !ok || !s.ActivatesAt.Equal(other.ActivatesAt) || !s.ExpiresAt.Equal(other.ExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// countValid returns the number of signers whose key is valid at the time.
+func countValid(signers map[common.Address]Signer, t time.Time) int {
+	n := 0
+	for _, s := range signers {
+		if s.ValidAt(t) {
+			n++
+		}
+	}
+	return n
+}
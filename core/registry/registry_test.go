@@ -0,0 +1,340 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// staticSource is a source of fixed signers, or of an error.
+type staticSource struct {
+	signers []Signer
+	err     error
+}
+
+func (s *staticSource) Name() string { return "static" }
+
+func (s *staticSource) Load(context.Context) ([]Signer, error) { return s.signers, s.err }
+
+// committeeCaller answers getSigners calls with the addresses.
+type committeeCaller struct {
+	addrs []common.Address
+}
+
+func (c committeeCaller) CallContract(_ context.Context, _ ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	src, err := NewContractSource(nil, common.Address{})
+	if err != nil {
+		return nil, err
+	}
+	return src.abi.Methods["getSigners"].Outputs.Pack(c.addrs)
+}
+
+// newTestKey returns a public key of the type, with its peer ID and address.
+func newTestKey(t *testing.T, keyType int) (crypto.PubKey, peer.ID, common.Address) {
+	t.Helper()
+	_, pub, err := crypto.GenerateKeyPair(keyType, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, id, global.PeerIDToAddress(id)
+}
+
+// writeSigners writes a signer file of the entries and returns its path.
+func writeSigners(t *testing.T, entries ...signerEntry) string {
+	t.Helper()
+	data, err := json.Marshal(signerFile{Signers: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signers.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileSource(t *testing.T) {
+	pub, id, addr := newTestKey(t, crypto.Ed25519)
+	raw, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := crypto.ConfigEncodeKey(raw)
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	tests := []struct {
+		name  string
+		entry signerEntry
+		want  common.Address
+		key   bool
+		err   bool
+	}{
+		{"peer id", signerEntry{PeerID: id.String()}, addr, true, false},
+		{"public key", signerEntry{PublicKey: encoded}, addr, true, false},
+		{"matching address", signerEntry{PublicKey: encoded, Address: addr.Hex()}, addr, true, false},
+		{"address only", signerEntry{Address: other.Hex()}, other, false, false},
+		{"mismatching address", signerEntry{PeerID: id.String(), Address: other.Hex()}, common.Address{}, false, true},
+		{"invalid address", signerEntry{Address: "0x01"}, common.Address{}, false, true},
+		{"invalid peer id", signerEntry{PeerID: "peer"}, common.Address{}, false, true},
+		{"invalid public key", signerEntry{PublicKey: "key"}, common.Address{}, false, true},
+		{"empty", signerEntry{Name: "node-1"}, common.Address{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signers, err := NewFileSource(writeSigners(t, tt.entry)).Load(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Errorf("Load() = %+v, want an error", signers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if len(signers) != 1 || signers[0].Address != tt.want || (signers[0].PublicKey != nil) != tt.key {
+				t.Errorf("Load() = %+v, want %s with public key %t", signers, tt.want, tt.key)
+			}
+		})
+	}
+
+	if _, err := NewFileSource(filepath.Join(t.TempDir(), "missing.json")).Load(context.Background()); err == nil {
+		t.Error("Load() of a missing file error = nil, want an error")
+	}
+}
+
+func TestContractSource(t *testing.T) {
+	addrs := []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		common.HexToAddress("0x0000000000000000000000000000000000000002"),
+	}
+	src, err := NewContractSource(committeeCaller{addrs: addrs}, common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(signers) != len(addrs) {
+		t.Fatalf("Load() = %d signers, want %d", len(signers), len(addrs))
+	}
+	for i, s := range signers {
+		if s.Address != addrs[i] || s.PublicKey != nil {
+			t.Errorf("Load()[%d] = %+v, want %s without public key", i, s, addrs[i])
+		}
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	pub, _, addr := newTestKey(t, crypto.Secp256k1)
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	unknown := common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+	// The contract only knows the address, the file knows the key and the
+	// name of the same signer
+	contract := &staticSource{signers: []Signer{{Address: addr}, {Address: other}}}
+	file := &staticSource{signers: []Signer{{Name: "node-1", Address: addr, PublicKey: pub}}}
+	r := New(file, contract)
+	if r.IsAuthorized(addr) {
+		t.Error("IsAuthorized() before Reload() = true, want false")
+	}
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+
+	tests := []struct {
+		addr common.Address
+		want bool
+		name string
+		key  bool
+	}{
+		{addr, true, "node-1", true},
+		{other, true, "", false},
+		{unknown, false, "", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsAuthorized(tt.addr); got != tt.want {
+			t.Errorf("IsAuthorized(%s) = %t, want %t", tt.addr, got, tt.want)
+		}
+		s, _ := r.Signer(tt.addr)
+		if s.Name != tt.name || (s.PublicKey != nil) != tt.key {
+			t.Errorf("Signer(%s) = %+v, want name %q with public key %t", tt.addr, s, tt.name, tt.key)
+		}
+	}
+	if signers := r.Signers(); len(signers) != 2 || signers[0].Address != other {
+		t.Errorf("Signers() = %+v, want 2 signers ordered by address", signers)
+	}
+
+	// A failed reload keeps the current signers
+	contract.err = errors.New("unavailable")
+	if err := r.Reload(context.Background()); err == nil {
+		t.Error("Reload() of a failing source error = nil, want an error")
+	}
+	if !r.IsAuthorized(other) {
+		t.Error("IsAuthorized() after a failed Reload() = false, want true")
+	}
+
+	// A removed signer is not authorized after the next reload
+	contract.err = nil
+	contract.signers = contract.signers[:1]
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if r.IsAuthorized(other) || !r.IsAuthorized(addr) {
+		t.Error("Reload() did not remove the signer missing from the sources")
+	}
+}
+
+func TestSignerValidAt(t *testing.T) {
+	activation := time.Unix(1700000000, 0)
+	expiry := activation.Add(24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		signer Signer
+		t      time.Time
+		want   bool
+	}{
+		{"unbounded", Signer{}, activation, true},
+		{"before activation", Signer{ActivatesAt: activation}, activation.Add(-time.Second), false},
+		{"at activation", Signer{ActivatesAt: activation}, activation, true},
+		{"before expiry", Signer{ExpiresAt: expiry}, expiry.Add(-time.Second), true},
+		// The key is not valid anymore at its expiry
+		{"at expiry", Signer{ExpiresAt: expiry}, expiry, false},
+		{"within window", Signer{ActivatesAt: activation, ExpiresAt: expiry}, activation.Add(time.Hour), true},
+		{"after window", Signer{ActivatesAt: activation, ExpiresAt: expiry}, expiry.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := tt.signer.ValidAt(tt.t); got != tt.want {
+			t.Errorf("%s: ValidAt(%s) = %t, want %t", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestFileSourceWindows(t *testing.T) {
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tests := []struct {
+		name       string
+		activation int64
+		expiry     int64
+		err        bool
+	}{
+		{"unbounded", 0, 0, false},
+		{"activation only", 1700000000, 0, false},
+		{"expiry only", 0, 1700000000, false},
+		{"window", 1700000000, 1800000000, false},
+		{"expiry at activation", 1700000000, 1700000000, true},
+		{"expiry before activation", 1800000000, 1700000000, true},
+	}
+	for _, tt := range tests {
+		path := writeSigners(t, signerEntry{Name: "node-1", Address: addr.Hex(), Activation: tt.activation, Expiry: tt.expiry})
+		signers, err := NewFileSource(path).Load(context.Background())
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: Load() error = nil, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Load() error: %v", tt.name, err)
+			continue
+		}
+		s := signers[0]
+		if (tt.activation == 0) != s.ActivatesAt.IsZero() || (tt.activation != 0 && s.ActivatesAt.Unix() != tt.activation) {
+			t.Errorf("%s: Load() activation = %s, want %d", tt.name, s.ActivatesAt, tt.activation)
+		}
+		if (tt.expiry == 0) != s.ExpiresAt.IsZero() || (tt.expiry != 0 && s.ExpiresAt.Unix() != tt.expiry) {
+			t.Errorf("%s: Load() expiry = %s, want %d", tt.name, s.ExpiresAt, tt.expiry)
+		}
+	}
+}
+
+func TestRegistryKeyRotation(t *testing.T) {
+	now := time.Now()
+	_, _, oldKey := newTestKey(t, crypto.Secp256k1)
+	_, _, newKey := newTestKey(t, crypto.Secp256k1)
+	rotation := now.Add(time.Hour)
+
+	// The new key is activated before the old one expires
+	file := &staticSource{signers: []Signer{
+		{Name: "node-1", Address: oldKey, ExpiresAt: rotation.Add(10 * time.Minute)},
+		{Name: "node-1", Address: newKey, ActivatesAt: rotation},
+	}}
+	r := New(file)
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		addr common.Address
+		t    time.Time
+		want bool
+	}{
+		{"old key now", oldKey, now, true},
+		{"new key now", newKey, now, false},
+		{"old key within overlap", oldKey, rotation.Add(5 * time.Minute), true},
+		{"new key within overlap", newKey, rotation.Add(5 * time.Minute), true},
+		{"old key after expiry", oldKey, rotation.Add(time.Hour), false},
+		{"new key after expiry", newKey, rotation.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := r.IsAuthorizedAt(tt.addr, tt.t); got != tt.want {
+			t.Errorf("%s: IsAuthorizedAt() = %t, want %t", tt.name, got, tt.want)
+		}
+		s, ok := r.SignerAt(tt.addr, tt.t)
+		if ok != tt.want || (ok && s.Identity() != "node-1") {
+			t.Errorf("%s: SignerAt() = %+v, %t, want node-1 %t", tt.name, s, ok, tt.want)
+		}
+	}
+	// A key which is not valid now is still known, so historic rates
+	// signed with it are verified
+	if s, ok := r.Signer(newKey); !ok || s.ActivatesAt.IsZero() {
+		t.Errorf("Signer() of a future key = %+v, %t, want the key with its activation", s, ok)
+	}
+	if len(r.Signers()) != 2 {
+		t.Errorf("Signers() = %d keys, want 2", len(r.Signers()))
+	}
+
+	// The old key is removed once its rates are not synced anymore
+	file.signers = file.signers[1:]
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if r.IsAuthorizedAt(oldKey, now) {
+		t.Error("IsAuthorizedAt() of a removed key = true, want false")
+	}
+}
+
+func TestRegistryReloadKeepsWindows(t *testing.T) {
+	_, _, addr := newTestKey(t, crypto.Secp256k1)
+	expiry := time.Now().Add(-time.Minute)
+	// The contract does not know the windows, the file does
+	file := &staticSource{signers: []Signer{{Name: "node-1", Address: addr, ExpiresAt: expiry}}}
+	contract := &staticSource{signers: []Signer{{Address: addr}}}
+	r := New(file, contract)
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	s, ok := r.Signer(addr)
+	if !ok || !s.ExpiresAt.Equal(expiry) || s.Name != "node-1" {
+		t.Errorf("Signer() = %+v, want node-1 expiring at %s", s, expiry)
+	}
+	if r.IsAuthorized(addr) {
+		t.Error("IsAuthorized() of an expired key listed by the contract = true, want false")
+	}
+}
@@ -0,0 +1,203 @@
+package signer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"gossip-price/core/metrics"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxRequestSize is the maximum size of a signing request.
+const maxRequestSize = 64 << 10
+
+// Daemon is the signing daemon. It signs the messages of a node with its
+// key, if they are allowed by the policy.
+type Daemon struct {
+	mu       sync.Mutex
+	signer   *protocol.LocalSigner
+	enforcer *enforcer
+	mux      *http.ServeMux
+}
+
+// NewDaemon returns a new daemon signing with the key.
+func NewDaemon(key crypto.PrivKey, policy Policy) (*Daemon, error) {
+	s, err := protocol.NewLocalSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	d := &Daemon{
+		signer:   s,
+		enforcer: newEnforcer(policy),
+		mux:      http.NewServeMux(),
+	}
+	d.mux.HandleFunc("/v1/key", d.handleKey)
+	d.mux.HandleFunc("/v1/sign", d.handleSign)
+	d.mux.Handle("/metrics", metrics.Handler())
+	return d, nil
+}
+
+// SetKey replaces the key of the daemon, e.g. after a key rotation. The
+// signing history is kept, so the policy still applies to the messages
+// signed with the previous key. The nodes pick up the new key when they
+// reload their remote signer.
+func (d *Daemon) SetKey(key crypto.PrivKey) error {
+	s, err := protocol.NewLocalSigner(key)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.signer = s
+	return nil
+}
+
+// current returns the current signer of the daemon.
+func (d *Daemon) current() *protocol.LocalSigner {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.signer
+}
+
+// Handler returns the HTTP handler of the daemon.
+func (d *Daemon) Handler() http.Handler {
+	return d.mux
+}
+
+// Serve serves the daemon on the address until the context is canceled.
+// Addresses in the unix:///path/to/socket form listen on a Unix socket,
+// which is only accessible by the user of the daemon. Other addresses
+// listen on TCP and require mutual TLS.
+func (d *Daemon) Serve(ctx context.Context, addr string, tlsConfig TLSConfig) error {
+	var (
+		ln  net.Listener
+		err error
+	)
+	if path, ok := socketPath(addr); ok {
+		// A socket left by a crashed daemon would fail the listen
+		_ = os.Remove(path)
+		if ln, err = net.Listen("unix", path); err != nil {
+			return fmt.Errorf("signer error, unable to listen on %s: %w", path, err)
+		}
+		if err = os.Chmod(path, 0o600); err != nil {
+			ln.Close()
+			return fmt.Errorf("signer error, unable to restrict socket permissions: %w", err)
+		}
+	} else {
+		cfg, err := tlsConfig.config(true)
+		if err != nil {
+			return err
+		}
+		if ln, err = tls.Listen("tcp", addr, cfg); err != nil {
+			return fmt.Errorf("signer error, unable to listen on %s: %w", addr, err)
+		}
+	}
+
+	srv := &http.Server{
+		Handler:      d.mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Printf("Signing daemon of %s listening on %s", d.current().Address(), addr)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("signer error, %w", err)
+	}
+	return nil
+}
+
+func (d *Daemon) handleKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	s := d.current()
+	pub, err := crypto.MarshalPublicKey(s.PublicKey())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keyResponse{
+		Address:   s.Address(),
+		Scheme:    s.Scheme(),
+		PublicKey: crypto.ConfigEncodeKey(pub),
+	})
+}
+
+func (d *Daemon) handleSign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	msg := &protocol.ProtocolMessage{}
+	if err := msg.UnmarshalJSON(data); err != nil || msg.Version != protocol.SigningVersion || msg.MsgId == "" || msg.Pair == "" {
+		metrics.SignerRequests.WithLabelValues("invalid").Inc()
+		writeError(w, http.StatusBadRequest, global.ErrInvalidMessage)
+		return
+	}
+
+	sig, err := d.sign(r.Context(), msg, time.Now())
+	switch {
+	case errors.Is(err, global.ErrSigningDenied):
+		log.Printf("Denied to sign message(%s) of %s: %v", msg.MsgId, msg.Pair, err)
+		metrics.SignerRequests.WithLabelValues("denied").Inc()
+		writeError(w, http.StatusForbidden, err)
+	case err != nil:
+		metrics.SignerRequests.WithLabelValues("invalid").Inc()
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		metrics.SignerRequests.WithLabelValues("signed").Inc()
+		writeJSON(w, http.StatusOK, signResponse{Signature: sig})
+	}
+}
+
+// sign checks the message against the policy, signs it and records it.
+func (d *Daemon) sign(ctx context.Context, msg *protocol.ProtocolMessage, now time.Time) (protocol.Signature, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.enforcer.check(msg, now); err != nil {
+		return nil, err
+	}
+	sig, err := d.signer.Sign(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	d.enforcer.record(msg, now)
+	return sig, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Signing daemon error, unable to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,111 @@
+package signer
+
+import (
+	"fmt"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"math"
+	"time"
+)
+
+// signedTTL is how long the signed message ids are remembered, to refuse
+// signing a message id twice with different prices.
+const signedTTL = time.Hour
+
+// Policy is the signing policy of the daemon. Zero values disable the
+// corresponding check.
+type Policy struct {
+	// MaxDeviation is the maximum change of the price of a pair from the
+	// last signed price, in basis points.
+	MaxDeviation int
+	// DeviationWindow is how long the last signed price is used by the
+	// deviation check. After it, any price is signed again, so the signer
+	// does not refuse to sign forever after a large price move.
+	DeviationWindow time.Duration
+	// RateLimit is the maximum number of signatures of a pair per
+	// RateWindow.
+	RateLimit  int
+	RateWindow time.Duration
+	// MaxSkew is the maximum difference between the signed time of a
+	// message and the time of the daemon.
+	MaxSkew time.Duration
+}
+
+// signedPrice is a price signed by the daemon.
+type signedPrice struct {
+	price float64
+	at    time.Time
+}
+
+// pairState is the signing history of a pair.
+type pairState struct {
+	last     signedPrice
+	signedAt []time.Time
+	msgs     map[string]signedPrice
+}
+
+// enforcer checks the messages against the policy, and records the signed
+// ones. It's not safe for concurrent use, the daemon checks, signs and
+// records a message while holding its lock.
+type enforcer struct {
+	policy Policy
+	pairs  map[string]*pairState
+}
+
+func newEnforcer(policy Policy) *enforcer {
+	return &enforcer{policy: policy, pairs: make(map[string]*pairState)}
+}
+
+// check returns an error wrapping global.ErrSigningDenied if the policy
+// denies to sign the message.
+func (e *enforcer) check(msg *protocol.ProtocolMessage, now time.Time) error {
+	if math.IsNaN(msg.Price) || math.IsInf(msg.Price, 0) || msg.Price <= 0 {
+		return fmt.Errorf("%w: invalid price %v", global.ErrSigningDenied, msg.Price)
+	}
+	if p := e.policy.MaxSkew; p > 0 && (msg.SignedTime.Before(now.Add(-p)) || msg.SignedTime.After(now.Add(p))) {
+		return fmt.Errorf("%w: signed time %s is too far from %s", global.ErrSigningDenied, msg.SignedTime, now)
+	}
+	s := e.state(msg.Pair, now)
+	if prev, ok := s.msgs[msg.MsgId]; ok && prev.price != msg.Price {
+		return fmt.Errorf("%w: message %s is already signed with price %v", global.ErrSigningDenied, msg.MsgId, prev.price)
+	}
+	if e.policy.RateLimit > 0 && len(s.signedAt) >= e.policy.RateLimit {
+		return fmt.Errorf("%w: rate limit of %s exceeded", global.ErrSigningDenied, msg.Pair)
+	}
+	if e.policy.MaxDeviation > 0 && s.last.price > 0 && now.Sub(s.last.at) < e.policy.DeviationWindow {
+		if dev := math.Abs(msg.Price-s.last.price) / s.last.price * 10000; dev > float64(e.policy.MaxDeviation) {
+			return fmt.Errorf("%w: price %v deviates %.0f bps from last signed price %v", global.ErrSigningDenied, msg.Price, dev, s.last.price)
+		}
+	}
+	return nil
+}
+
+// record records the signed message.
+func (e *enforcer) record(msg *protocol.ProtocolMessage, now time.Time) {
+	s := e.state(msg.Pair, now)
+	signed := signedPrice{price: msg.Price, at: now}
+	s.last = signed
+	s.signedAt = append(s.signedAt, now)
+	s.msgs[msg.MsgId] = signed
+}
+
+// state returns the state of the pair, without the signatures older than
+// the rate window and the message ids older than signedTTL.
+func (e *enforcer) state(pair string, now time.Time) *pairState {
+	s, ok := e.pairs[pair]
+	if !ok {
+		s = &pairState{msgs: make(map[string]signedPrice)}
+		e.pairs[pair] = s
+	}
+	i := 0
+	for i < len(s.signedAt) && now.Sub(s.signedAt[i]) >= e.policy.RateWindow {
+		i++
+	}
+	s.signedAt = s.signedAt[i:]
+	for id, m := range s.msgs {
+		if now.Sub(m.at) >= signedTTL {
+			delete(s.msgs, id)
+		}
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnforcer(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	policy := Policy{
+		MaxDeviation:    500,
+		DeviationWindow: 10 * time.Minute,
+		RateLimit:       3,
+		RateWindow:      time.Minute,
+		MaxSkew:         30 * time.Second,
+	}
+	type request struct {
+		id     string
+		pair   string
+		price  float64
+		signed time.Duration
+		at     time.Duration
+		denied bool
+	}
+	tests := []struct {
+		name     string
+		policy   Policy
+		requests []request
+	}{
+		{"valid", policy, []request{{"1", "ETH/USD", 2000, 0, 0, false}}},
+		{"invalid price", policy, []request{
+			{"1", "ETH/USD", 0, 0, 0, true},
+			{"2", "ETH/USD", -1, 0, 0, true},
+			{"3", "ETH/USD", math.NaN(), 0, 0, true},
+			{"4", "ETH/USD", math.Inf(1), 0, 0, true},
+		}},
+		{"skew", policy, []request{
+			{"1", "ETH/USD", 2000, 30 * time.Second, 0, false},
+			{"2", "ETH/USD", 2000, -30 * time.Second, 0, false},
+			{"3", "ETH/USD", 2000, 31 * time.Second, 0, true},
+			{"4", "ETH/USD", 2000, -31 * time.Second, 0, true},
+		}},
+		// The same message id is only signed again with the same price
+		{"equivocation", policy, []request{
+			{"1", "ETH/USD", 2000, 0, 0, false},
+			{"1", "ETH/USD", 2000, 0, time.Second, false},
+			{"1", "ETH/USD", 2001, 0, 2 * time.Second, true},
+			{"1", "ETH/USD", 2001, 0, time.Hour + time.Second, false},
+		}},
+		{"rate limit", policy, []request{
+			{"1", "ETH/USD", 2000, 0, 0, false},
+			{"2", "ETH/USD", 2000, 0, 10 * time.Second, false},
+			{"3", "ETH/USD", 2000, 0, 20 * time.Second, false},
+			{"4", "ETH/USD", 2000, 0, 30 * time.Second, true},
+			// The limit is per pair
+			{"5", "BTC/USD", 40000, 0, 30 * time.Second, false},
+			// The first signature left the window
+			{"6", "ETH/USD", 2000, 0, time.Minute, false},
+		}},
+		{"deviation", policy, []request{
+			{"1", "ETH/USD", 2000, 0, 0, false},
+			// 5% of 2000
+			{"2", "ETH/USD", 2100, 0, time.Minute, false},
+			{"3", "ETH/USD", 2206, 0, 2 * time.Minute, true},
+			{"4", "ETH/USD", 1994, 0, 3 * time.Minute, true},
+			// The last signed price expired
+			{"5", "ETH/USD", 3000, 0, 12 * time.Minute, false},
+		}},
+		{"disabled", Policy{}, []request{
+			{"1", "ETH/USD", 2000, time.Hour, 0, false},
+			{"1", "ETH/USD", 2000, 0, 0, false},
+			{"2", "ETH/USD", 9000, 0, 0, false},
+			{"3", "ETH/USD", 1, 0, 0, false},
+		}},
+	}
+	for _, tt := range tests {
+		e := newEnforcer(tt.policy)
+		for i, req := range tt.requests {
+			at := now.Add(req.at)
+			msg := &protocol.ProtocolMessage{MsgId: req.id, Pair: req.pair, Price: req.price, SignedTime: at.Add(req.signed)}
+			err := e.check(msg, at)
+			if req.denied {
+				if !errors.Is(err, global.ErrSigningDenied) {
+					t.Errorf("%s: check() of request %d error = %v, want %v", tt.name, i, err, global.ErrSigningDenied)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: check() of request %d error: %v", tt.name, i, err)
+				continue
+			}
+			e.record(msg, at)
+		}
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon, err := NewDaemon(key, Policy{MaxDeviation: 100, DeviationWindow: time.Minute, MaxSkew: time.Minute})
+	if err != nil {
+		t.Fatalf("NewDaemon() error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	addr := "unix://" + filepath.Join(t.TempDir(), "signer.sock")
+	go func() {
+		if err := daemon.Serve(ctx, addr, TLSConfig{}); err != nil {
+			t.Errorf("Serve() error: %v", err)
+		}
+	}()
+
+	var remote *Remote
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if remote, err = NewRemote(ctx, RemoteConfig{URL: addr, Timeout: 5 * time.Second}); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("NewRemote() error: %v", err)
+	}
+	local, err := protocol.NewLocalSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote.Address() != local.Address() || remote.Scheme() != local.Scheme() {
+		t.Fatalf("NewRemote() = %s %s signer, want %s %s", remote.Scheme(), remote.Address(), local.Scheme(), local.Address())
+	}
+
+	// The signature of the daemon is verified like the local ones
+	msg := &protocol.ProtocolMessage{MsgId: "ETH-USD-1", Pair: "ETH/USD", Price: 2000}
+	if _, err := msg.Sign(ctx, remote); err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	if err := protocol.Verify(msg, nil); err != nil || msg.Signer != local.Address() {
+		t.Errorf("Verify() of the remote signature error = %v, signer %s", err, msg.Signer)
+	}
+
+	// The policy of the daemon denies a price moving more than 1%
+	msg = &protocol.ProtocolMessage{MsgId: "ETH-USD-2", Pair: "ETH/USD", Price: 2100}
+	if _, err := msg.Sign(ctx, remote); !errors.Is(err, global.ErrSigningDenied) {
+		t.Errorf("Sign() of a deviating price error = %v, want %v", err, global.ErrSigningDenied)
+	}
+}
@@ -0,0 +1,161 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RemoteConfig is the configuration of the remote signer.
+type RemoteConfig struct {
+	// URL is the address of the daemon, unix:///path/to/socket or
+	// https://host:port.
+	URL string
+	// TLS is the mutual TLS configuration, required for https URLs.
+	TLS TLSConfig
+	// Timeout is the timeout of a signing request.
+	Timeout time.Duration
+}
+
+// Remote implements the protocol.Signer interface with a signing daemon.
+// Every signature returned by the daemon is verified, so a misconfigured
+// daemon cannot make the node broadcast invalid messages.
+type Remote struct {
+	client  *http.Client
+	baseURL string
+	address common.Address
+	scheme  string
+	pub     crypto.PubKey
+}
+
+// NewRemote returns a new remote signer of the daemon. It fetches the key
+// of the daemon, so the daemon must be running.
+func NewRemote(ctx context.Context, c RemoteConfig) (*Remote, error) {
+	transport := &http.Transport{}
+	r := &Remote{
+		client:  &http.Client{Transport: transport, Timeout: c.Timeout},
+		baseURL: strings.TrimSuffix(c.URL, "/"),
+	}
+	if path, ok := socketPath(c.URL); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		// The host is ignored by the dialer
+		r.baseURL = "http://signer"
+	} else {
+		if !strings.HasPrefix(c.URL, "https://") {
+			return nil, fmt.Errorf("signer error, remote signer URL must be unix:// or https://: %s", c.URL)
+		}
+		cfg, err := c.TLS.config(false)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = cfg
+	}
+
+	var key keyResponse
+	if err := r.do(ctx, http.MethodGet, "/v1/key", nil, &key); err != nil {
+		return nil, err
+	}
+	raw, err := crypto.ConfigDecodeKey(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("signer error, invalid public key of daemon: %w", err)
+	}
+	if r.pub, err = crypto.UnmarshalPublicKey(raw); err != nil {
+		return nil, fmt.Errorf("signer error, invalid public key of daemon: %w", err)
+	}
+	id, err := peer.IDFromPublicKey(r.pub)
+	if err != nil || global.PeerIDToAddress(id) != key.Address {
+		return nil, fmt.Errorf("signer error, daemon address %s does not match its public key", key.Address)
+	}
+	r.address = key.Address
+	r.scheme = key.Scheme
+	return r, nil
+}
+
+// Address implements the protocol.Signer interface.
+func (r *Remote) Address() common.Address {
+	return r.address
+}
+
+// Scheme implements the protocol.Signer interface.
+func (r *Remote) Scheme() string {
+	return r.scheme
+}
+
+// PublicKey returns the public key of the daemon.
+func (r *Remote) PublicKey() crypto.PubKey {
+	return r.pub
+}
+
+// Close closes the idle connections to the daemon, e.g. after the signer
+// is replaced.
+func (r *Remote) Close() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+// Sign implements the protocol.Signer interface. Messages denied by the
+// policy of the daemon return an error wrapping global.ErrSigningDenied.
+func (r *Remote) Sign(ctx context.Context, msg *protocol.ProtocolMessage) (protocol.Signature, error) {
+	data, err := msg.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var res signResponse
+	if err := r.do(ctx, http.MethodPost, "/v1/sign", data, &res); err != nil {
+		return nil, err
+	}
+	signed := *msg
+	signed.Signature = res.Signature
+	if err := protocol.Verify(&signed, r.pub); err != nil {
+		return nil, fmt.Errorf("signer error, invalid signature of daemon: %w", err)
+	}
+	return res.Signature, nil
+}
+
+// do sends the request to the daemon and decodes the response to res.
+func (r *Remote) do(ctx context.Context, method, path string, body []byte, res any) error {
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("signer error, %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer error, request to daemon failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestSize))
+	if err != nil {
+		return fmt.Errorf("signer error, unable to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.Unmarshal(data, &e)
+		if resp.StatusCode == http.StatusForbidden {
+			// The daemon already prefixes the reason with the error
+			return fmt.Errorf("%w: %s", global.ErrSigningDenied, strings.TrimPrefix(e.Error, global.ErrSigningDenied.Error()+": "))
+		}
+		return fmt.Errorf("signer error, daemon returned %s: %s", resp.Status, e.Error)
+	}
+	if err := json.Unmarshal(data, res); err != nil {
+		return fmt.Errorf("signer error, invalid response: %w", err)
+	}
+	return nil
+}
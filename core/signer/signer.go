@@ -0,0 +1,95 @@
+// Package signer implements a signing daemon, which holds the key of a node
+// and signs its messages, and the remote signer used by the node to talk
+// to it. The node then never holds the raw key material, and the daemon
+// enforces a signing policy even if the node is compromised.
+//
+// The daemon serves a small HTTP API, either on a Unix socket or on TCP
+// with mutual TLS:
+//
+//	GET  /v1/key     address, scheme and public key of the signer
+//	POST /v1/sign    signature of the message in the request body
+//	GET  /metrics    Prometheus metrics of the daemon
+//
+// Messages denied by the policy are answered with 403 Forbidden.
+package signer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	protocol "gossip-price/core/gossip"
+	"os"
+	"strings"
+)
+
+// unixScheme is the URL scheme of Unix socket addresses.
+const unixScheme = "unix://"
+
+// keyResponse is the response of /v1/key.
+type keyResponse struct {
+	Address   common.Address `json:"address"`
+	Scheme    string         `json:"scheme"`
+	PublicKey string         `json:"public_key"`
+}
+
+// signResponse is the response of /v1/sign.
+type signResponse struct {
+	Signature protocol.Signature `json:"signature"`
+}
+
+// errorResponse is the response of failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// TLSConfig is the mutual TLS configuration of the daemon or of the remote
+// signer. CAFile is the CA of the other side: the CA of the client
+// certificates on the daemon, the CA of the daemon certificate on the
+// remote signer.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// config returns the TLS configuration which requires and verifies the
+// certificate of the other side.
+func (c TLSConfig) config(server bool) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" || c.CAFile == "" {
+		return nil, errors.New("signer error, mutual TLS requires a certificate, a key and a CA")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signer error, unable to load certificate: %w", err)
+	}
+	ca, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("signer error, unable to read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("signer error, no certificate in CA file %s", c.CAFile)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if server {
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// socketPath returns the path of a unix:// address, and false for other
+// addresses.
+func socketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, unixScheme), true
+}
@@ -0,0 +1,251 @@
+// Package statesync implements the /gossip-price/sync/1.0.0 protocol. Nodes
+// use it to fetch the pending messages and the latest stored rates of their
+// peers, so a node which joins in the middle of a round, or which was
+// restarted, does not have to wait for the next gossip messages.
+//
+// Every request is sent on its own stream. The requester writes a single
+// JSON encoded Request and closes its side of the stream, the responder
+// answers with a single JSON encoded Response.
+package statesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/consensus"
+	"gossip-price/core/consensus/db"
+	protocol "gossip-price/core/gossip"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ProtocolID is the libp2p protocol id of the sync protocol.
+const ProtocolID = "/gossip-price/sync/1.0.0"
+
+// Request types
+const (
+	// RequestPending requests the pending messages with their signatures.
+	RequestPending = "pending"
+	// RequestLatest requests the latest stored rates with their signatures.
+	RequestLatest = "latest"
+)
+
+// maxMessageSize is the maximum size of a request or a response.
+const maxMessageSize = 16 << 20
+
+// streamTimeout is the deadline of a request, including the response.
+const streamTimeout = 10 * time.Second
+
+// Request is a request of the sync protocol. Pending messages can be
+// filtered by the pair, and by the message id or the round of the pair.
+// Latest rates are returned for the pair, or for all pairs if it's empty.
+type Request struct {
+	Type  string  `json:"type"`
+	Pair  string  `json:"pair,omitempty"`
+	MsgId string  `json:"id,omitempty"`
+	Round *uint64 `json:"round,omitempty"`
+}
+
+// Response is the response of the sync protocol.
+type Response struct {
+	Error   string           `json:"error,omitempty"`
+	Pending []PendingMessage `json:"pending,omitempty"`
+	Rates   []Rate           `json:"rates,omitempty"`
+}
+
+// PendingMessage is a message which is collecting signatures, with the
+// signed messages of all of its signers.
+type PendingMessage struct {
+	MsgId    string                      `json:"id"`
+	Pair     string                      `json:"pair"`
+	Messages []*protocol.ProtocolMessage `json:"messages"`
+}
+
+// Rate is a stored rate with all of its signatures.
+type Rate struct {
+	ID             string             `json:"id"`
+	Pair           string             `json:"pair"`
+	Price          string             `json:"price"`
+	FirstSigner    string             `json:"first_signer"`
+	Trigger        string             `json:"trigger"`
+	LastSignedTime time.Time          `json:"last_signed_time"`
+	CreatedTime    time.Time          `json:"created_time"`
+	Signatures     []db.SignatureData `json:"signatures"`
+}
+
+// Service serves the sync protocol on the host, and requests the state of
+// the peers.
+type Service struct {
+	host   host.Host
+	engine *consensus.Engine
+	pairs  []string
+}
+
+// New returns a new sync service of the engine, and registers its stream
+// handler on the host.
+func New(h host.Host, engine *consensus.Engine, pairs []string) *Service {
+	s := &Service{
+		host:   h,
+		engine: engine,
+		pairs:  pairs,
+	}
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+	return s
+}
+
+// handleStream answers a single request of a peer.
+func (s *Service) handleStream(stream network.Stream) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(streamTimeout))
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(stream, maxMessageSize)).Decode(&req); err != nil {
+		_ = stream.Reset()
+		return
+	}
+	res, err := s.handle(req)
+	if err != nil {
+		res = &Response{Error: err.Error()}
+	}
+	if err := json.NewEncoder(stream).Encode(res); err != nil {
+		log.Printf("Sync response to %s failed: %v", stream.Conn().RemotePeer(), err)
+		_ = stream.Reset()
+	}
+}
+
+// handle returns the response of the request.
+func (s *Service) handle(req Request) (*Response, error) {
+	switch req.Type {
+	case RequestPending:
+		msgId := req.MsgId
+		if req.Round != nil {
+			if req.Pair == "" {
+				return nil, fmt.Errorf("pair is required with round")
+			}
+			msgId = consensus.RoundID(req.Pair, *req.Round)
+		}
+		res := &Response{Pending: make([]PendingMessage, 0)}
+		for _, p := range s.engine.Pending(req.Pair) {
+			if msgId != "" && p.MsgId != msgId {
+				continue
+			}
+			msgs := make([]*protocol.ProtocolMessage, 0, len(p.Messages))
+			for i := range p.Messages {
+				msgs = append(msgs, &p.Messages[i])
+			}
+			res.Pending = append(res.Pending, PendingMessage{MsgId: p.MsgId, Pair: p.Pair, Messages: msgs})
+		}
+		return res, nil
+	case RequestLatest:
+		pairs := s.pairs
+		if req.Pair != "" {
+			pairs = []string{req.Pair}
+		}
+		res := &Response{Rates: make([]Rate, 0)}
+		for _, pair := range pairs {
+			latest, err := s.engine.Database().LatestRate(pair)
+			if err != nil {
+				continue
+			}
+			// LatestRate does not return the signatures of the rate
+			rate, err := s.engine.Database().GetRate(latest.ID)
+			if err != nil {
+				continue
+			}
+			res.Rates = append(res.Rates, Rate{
+				ID:             rate.ID,
+				Pair:           rate.Pair,
+				Price:          rate.Price,
+				FirstSigner:    rate.First_Signer,
+				Trigger:        rate.Trigger,
+				LastSignedTime: rate.LastSigned_Time,
+				CreatedTime:    rate.Created_Time,
+				Signatures:     rate.Signatures,
+			})
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("unknown request type: %q", req.Type)
+}
+
+// Request sends the request to the peer and returns its response.
+func (s *Service) Request(ctx context.Context, p peer.ID, req Request) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, streamTimeout)
+	defer cancel()
+
+	stream, err := s.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("sync error, unable to open stream to %s: %w", p, err)
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(stream).Encode(req); err != nil {
+		_ = stream.Reset()
+		return nil, fmt.Errorf("sync error, unable to send request to %s: %w", p, err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		_ = stream.Reset()
+		return nil, fmt.Errorf("sync error, unable to send request to %s: %w", p, err)
+	}
+	var res Response
+	if err := json.NewDecoder(io.LimitReader(stream, maxMessageSize)).Decode(&res); err != nil {
+		_ = stream.Reset()
+		return nil, fmt.Errorf("sync error, invalid response of %s: %w", p, err)
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("sync error, request failed on %s: %s", p, res.Error)
+	}
+	return &res, nil
+}
+
+// SupportingPeers returns the connected peers which support the sync
+// protocol.
+func (s *Service) SupportingPeers() []peer.ID {
+	var peers []peer.ID
+	for _, p := range s.host.Network().Peers() {
+		if ok, err := s.host.Peerstore().SupportsProtocols(p, ProtocolID); err == nil && len(ok) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// SignedMessages returns the signed messages of the signatures of the
+// rate, so they can be verified with protocol.Verify. Signatures without
+// a scheme, a price or a signed time, which were migrated from old rates,
+// cannot be verified and are skipped.
+func (r Rate) SignedMessages() []*protocol.ProtocolMessage {
+	msgs := make([]*protocol.ProtocolMessage, 0, len(r.Signatures))
+	for _, sig := range r.Signatures {
+		if sig.Scheme == "" || sig.Price == "" || sig.SignedTime.IsZero() {
+			continue
+		}
+		msg := &protocol.ProtocolMessage{
+			Version:    protocol.SigningVersion,
+			Scheme:     sig.Scheme,
+			MsgId:      r.ID,
+			Pair:       r.Pair,
+			Signer:     common.HexToAddress(sig.Signer),
+			SignedTime: sig.SignedTime,
+		}
+		price, err := strconv.ParseFloat(sig.Price, 64)
+		if err != nil {
+			continue
+		}
+		msg.Price = price
+		if err := msg.Signature.UnmarshalJSON([]byte(`"` + sig.Signature + `"`)); err != nil {
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
@@ -0,0 +1,216 @@
+package statesync
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/consensus"
+	"gossip-price/core/consensus/db"
+	"gossip-price/core/global"
+	protocol "gossip-price/core/gossip"
+	"testing"
+	"time"
+)
+
+// newTestEngine returns an engine of ETH/USD and BTC/USD with a memory
+// database, which finalizes the messages signed by 3 signers.
+func newTestEngine(t *testing.T) *consensus.Engine {
+	t.Helper()
+	prev := global.GPMinimumSignerCount
+	global.GPMinimumSignerCount = 3
+	t.Cleanup(func() { global.GPMinimumSignerCount = prev })
+
+	engine, err := consensus.NewEngine(consensus.Config{
+		Pairs:       []string{"ETH/USD", "BTC/USD"},
+		Database:    db.NewMemory(),
+		Aggregation: consensus.Aggregation{Method: consensus.AggregateMedian},
+		PendingTTL:  10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error: %v", err)
+	}
+	return engine
+}
+
+// newTestSigners returns n local signers of the scheme of the key type.
+func newTestSigners(t *testing.T, n int, keyType int) []*protocol.LocalSigner {
+	t.Helper()
+	signers := make([]*protocol.LocalSigner, 0, n)
+	for i := 0; i < n; i++ {
+		key, _, err := crypto.GenerateKeyPair(keyType, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := protocol.NewLocalSigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
+// sign returns the message of the pair signed by the signer.
+func sign(t *testing.T, signer protocol.Signer, msgId, pair string, price float64) protocol.ProtocolMessage {
+	t.Helper()
+	msg := protocol.ProtocolMessage{MsgId: msgId, Pair: pair, Price: price}
+	if _, err := msg.Sign(context.Background(), signer); err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	return msg
+}
+
+func newTestHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("libp2p.New() error: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestHandlePending(t *testing.T) {
+	engine := newTestEngine(t)
+	signer := newTestSigners(t, 1, crypto.Secp256k1)[0]
+	for _, msg := range []protocol.ProtocolMessage{
+		sign(t, signer, consensus.RoundID("ETH/USD", 1), "ETH/USD", 2000),
+		sign(t, signer, consensus.RoundID("ETH/USD", 2), "ETH/USD", 2001),
+		sign(t, signer, consensus.RoundID("BTC/USD", 1), "BTC/USD", 40000),
+	} {
+		engine.Append(msg)
+	}
+	s := &Service{engine: engine}
+	round := uint64(2)
+
+	tests := []struct {
+		name string
+		req  Request
+		want int
+		err  bool
+	}{
+		{"all", Request{Type: RequestPending}, 3, false},
+		{"pair", Request{Type: RequestPending, Pair: "ETH/USD"}, 2, false},
+		{"message id", Request{Type: RequestPending, MsgId: "BTC-USD-1"}, 1, false},
+		{"round", Request{Type: RequestPending, Pair: "ETH/USD", Round: &round}, 1, false},
+		{"unknown message id", Request{Type: RequestPending, MsgId: "ETH-USD-3"}, 0, false},
+		{"round without pair", Request{Type: RequestPending, Round: &round}, 0, true},
+		{"unknown type", Request{Type: "rates"}, 0, true},
+	}
+	for _, tt := range tests {
+		res, err := s.handle(tt.req)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: handle() error = nil, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: handle() error: %v", tt.name, err)
+			continue
+		}
+		if len(res.Pending) != tt.want {
+			t.Errorf("%s: handle() = %d pending messages, want %d", tt.name, len(res.Pending), tt.want)
+		}
+		for _, p := range res.Pending {
+			if len(p.Messages) != 1 || p.Messages[0].MsgId != p.MsgId || protocol.Verify(p.Messages[0], nil) != nil {
+				t.Errorf("%s: handle() = %+v, want the signed message of %s", tt.name, p, p.MsgId)
+			}
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	server, client := newTestHost(t), newTestHost(t)
+	engine := newTestEngine(t)
+	New(server, engine, []string{"ETH/USD", "BTC/USD"})
+	s := New(client, newTestEngine(t), []string{"ETH/USD", "BTC/USD"})
+
+	// A rate stored with EIP-712 and Ed25519 signatures
+	signers := append(newTestSigners(t, 2, crypto.Secp256k1), newTestSigners(t, 1, crypto.Ed25519)...)
+	var msgs []protocol.ProtocolMessage
+	for i, signer := range signers {
+		msgs = append(msgs, sign(t, signer, "ETH-USD-1", "ETH/USD", 2000+float64(i)))
+	}
+	var lastSigned time.Time
+	for _, msg := range msgs {
+		if msg.SignedTime.After(lastSigned) {
+			lastSigned = msg.SignedTime
+		}
+	}
+	if _, err := engine.Import("ETH-USD-1", "ETH/USD", "heartbeat", lastSigned, msgs); err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	engine.Append(msgs[0])
+	engine.Append(sign(t, newTestSigners(t, 1, crypto.Secp256k1)[0], "ETH-USD-2", "ETH/USD", 2010))
+
+	ctx := context.Background()
+	if err := client.Connect(ctx, peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	// The protocols of the peer are known once it's identified
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.SupportingPeers()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if peers := s.SupportingPeers(); len(peers) != 1 || peers[0] != server.ID() {
+		t.Fatalf("SupportingPeers() = %v, want %s", peers, server.ID())
+	}
+
+	res, err := s.Request(ctx, server.ID(), Request{Type: RequestPending})
+	if err != nil {
+		t.Fatalf("Request() of pending messages error: %v", err)
+	}
+	if len(res.Pending) != 1 || res.Pending[0].MsgId != "ETH-USD-2" || len(res.Pending[0].Messages) != 1 {
+		t.Errorf("Request() = %+v, want ETH-USD-2 with 1 message", res.Pending)
+	}
+
+	res, err = s.Request(ctx, server.ID(), Request{Type: RequestLatest})
+	if err != nil {
+		t.Fatalf("Request() of latest rates error: %v", err)
+	}
+	if len(res.Rates) != 1 || res.Rates[0].ID != "ETH-USD-1" || res.Rates[0].Trigger != "heartbeat" {
+		t.Fatalf("Request() = %+v, want the ETH-USD-1 rate", res.Rates)
+	}
+	// Every signature of the rate is verified again by the requester
+	signed := res.Rates[0].SignedMessages()
+	if len(signed) != len(msgs) {
+		t.Fatalf("SignedMessages() = %d messages, want %d", len(signed), len(msgs))
+	}
+	for i, msg := range signed {
+		want := msgs[i]
+		if msg.Signer != want.Signer || msg.Price != want.Price || !msg.SignedTime.Equal(want.SignedTime) || msg.Scheme != want.Scheme {
+			t.Errorf("SignedMessages()[%d] = %+v, want %+v", i, msg, want)
+		}
+		var pub crypto.PubKey
+		if msg.Scheme == protocol.SchemeEd25519 {
+			pub = signers[i].PublicKey()
+		}
+		if err := protocol.Verify(msg, pub); err != nil {
+			t.Errorf("Verify() of SignedMessages()[%d] error: %v", i, err)
+		}
+	}
+
+	if _, err = s.Request(ctx, server.ID(), Request{Type: "rates"}); err == nil {
+		t.Error("Request() of an unknown type error = nil, want an error")
+	}
+}
+
+func TestSignedMessagesSkipsMigrated(t *testing.T) {
+	rate := Rate{
+		ID:   "ETH-USD-1",
+		Pair: "ETH/USD",
+		Signatures: []db.SignatureData{
+			// Migrated without scheme, price and signed time
+			{Signer: "0x0000000000000000000000000000000000000001", Signature: "0x01"},
+			{Signer: "0x0000000000000000000000000000000000000002", Signature: "0x02", Scheme: "eip712", Price: "2000", SignedTime: time.Unix(1700000000, 0)},
+			{Signer: "0x0000000000000000000000000000000000000003", Signature: "0x03", Scheme: "eip712", Price: "price", SignedTime: time.Unix(1700000000, 0)},
+		},
+	}
+	msgs := rate.SignedMessages()
+	if len(msgs) != 1 || msgs[0].Price != 2000 || msgs[0].MsgId != rate.ID || msgs[0].Pair != rate.Pair {
+		t.Errorf("SignedMessages() = %+v, want the message of 0x...02", msgs)
+	}
+}
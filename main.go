@@ -2,13 +2,38 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gossip-price/core/consensus/db"
+	"gossip-price/core/global"
+	"gossip-price/core/identity"
 	server "gossip-price/core/node"
+	"gossip-price/core/signer"
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrate()
+			return
+		case "keygen":
+			keygen(os.Args[2:])
+			return
+		case "signer":
+			signingDaemon()
+			return
+		default:
+			log.Fatalf("Unknown command: %s", os.Args[1])
+		}
+	}
 
 	server, err := server.NewGossipServer()
 	if err != nil {
@@ -26,3 +51,116 @@ func main() {
 	}
 	<-server.Wait()
 }
+
+// migrate applies the database migrations and exits
+func migrate() {
+	database, err := db.Open(global.GPDatabaseUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+	if err = database.Migrate(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Database is up to date")
+}
+
+// keygen generates a new node key, writes it to an encrypted keystore file
+// and prints its peer ID, signer address and public key. The passphrase is
+// read from GP_NODEKEYPASSWORD or GP_NODEKEYPASSWORDFILE.
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	scheme := flags.String("scheme", global.GPSigningScheme, "signing scheme of the key, ed25519 or eip712")
+	out := flags.String("out", global.GPNodeKeyFile, "path of the keystore file")
+	_ = flags.Parse(args)
+	if *out == "" {
+		log.Fatal("keygen: -out or GP_NODEKEYFILE is required")
+	}
+
+	passphrase, err := identity.Passphrase(global.GPNodeKeyPassword, global.GPNodeKeyPassFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if passphrase == "" {
+		log.Print("keygen: the passphrase is empty, the key is not protected")
+	}
+	key, err := identity.Generate(*scheme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = identity.Save(*out, key, passphrase); err != nil {
+		log.Fatal(err)
+	}
+
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Keystore:   %s\n", *out)
+	fmt.Printf("Peer ID:    %s\n", id)
+	fmt.Printf("Address:    %s\n", global.PeerIDToAddress(id).Hex())
+	fmt.Printf("Public key: %s\n", crypto.ConfigEncodeKey(pub))
+}
+
+// signingDaemon runs the signing daemon with the key of GP_NODEKEYFILE,
+// until it's interrupted. On SIGHUP the key file is loaded again, so the
+// key can be rotated without restarting the daemon.
+func signingDaemon() {
+	if global.GPNodeKeyFile == "" {
+		log.Fatal("signer: GP_NODEKEYFILE is required")
+	}
+	key, err := loadDaemonKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	daemon, err := signer.NewDaemon(key, signer.Policy{
+		MaxDeviation:    global.GPSignerMaxDeviation,
+		DeviationWindow: time.Duration(global.GPSignerDevWindow) * time.Second,
+		RateLimit:       global.GPSignerRateLimit,
+		RateWindow:      time.Minute,
+		MaxSkew:         time.Duration(global.GPSignerMaxSkew) * time.Second,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			key, err := loadDaemonKey()
+			if err == nil {
+				err = daemon.SetKey(key)
+			}
+			if err != nil {
+				log.Printf("Reloading signing key failed, the previous key is kept: %v", err)
+				continue
+			}
+			id, _ := peer.IDFromPrivateKey(key)
+			log.Printf("Signing key reloaded, signing as %s", global.PeerIDToAddress(id))
+		}
+	}()
+	err = daemon.Serve(ctx, global.GPSignerListen, signer.TLSConfig{
+		CertFile: global.GPSignerTLSCert,
+		KeyFile:  global.GPSignerTLSKey,
+		CAFile:   global.GPSignerTLSCA,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadDaemonKey loads the key of the signing daemon from GP_NODEKEYFILE.
+func loadDaemonKey() (crypto.PrivKey, error) {
+	passphrase, err := identity.Passphrase(global.GPNodeKeyPassword, global.GPNodeKeyPassFile)
+	if err != nil {
+		return nil, err
+	}
+	return identity.Load(global.GPNodeKeyFile, passphrase)
+}
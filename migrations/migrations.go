@@ -0,0 +1,16 @@
+// Package migrations contains the versioned SQL migrations of the rate
+// database. Every migration is a file named <version>_<name>.sql, and is
+// applied once, in the order of the versions.
+package migrations
+
+import "embed"
+
+// Postgres contains the migrations of the PostgreSQL database.
+//
+//go:embed postgres/*.sql
+var Postgres embed.FS
+
+// SQLite contains the migrations of the SQLite database.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
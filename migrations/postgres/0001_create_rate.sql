@@ -0,0 +1,15 @@
+-- Initial schema of the rate table, as created by the previous Atlas
+-- based migrations. Databases created by Atlas already have the table,
+-- and possibly miss the pair column.
+CREATE TABLE IF NOT EXISTS rate (
+    id              text      NOT NULL,
+    pair            text      NOT NULL DEFAULT 'ETH/USD',
+    price           text      NOT NULL,
+    first_signer    text      NOT NULL,
+    sign_data       text      NOT NULL,
+    lastsigned_time timestamp NOT NULL,
+    created_time    timestamp NOT NULL,
+    PRIMARY KEY (id)
+);
+
+ALTER TABLE rate ADD COLUMN IF NOT EXISTS pair text NOT NULL DEFAULT 'ETH/USD';
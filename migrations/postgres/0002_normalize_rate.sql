@@ -0,0 +1,46 @@
+-- Stores the price as numeric and the times with time zone. The old
+-- timestamps were written in UTC.
+ALTER TABLE rate
+    ALTER COLUMN price TYPE numeric USING price::numeric,
+    ALTER COLUMN lastsigned_time TYPE timestamptz USING lastsigned_time AT TIME ZONE 'UTC',
+    ALTER COLUMN created_time TYPE timestamptz USING created_time AT TIME ZONE 'UTC';
+
+CREATE INDEX IF NOT EXISTS rate_pair_lastsigned_time_idx ON rate (pair, lastsigned_time DESC);
+
+-- Every signature of a rate is stored in its own row.
+CREATE TABLE rate_signature (
+    rate_id   text        NOT NULL REFERENCES rate (id) ON DELETE CASCADE,
+    signer    text        NOT NULL,
+    signature text        NOT NULL,
+    signed_at timestamptz,
+    PRIMARY KEY (rate_id, signer)
+);
+
+CREATE INDEX rate_signature_signer_idx ON rate_signature (signer);
+
+-- Carries forward the signatures of the sign_data column. It is either a
+-- JSON array of {signer, signature, signed_time} objects, or an object
+-- with the first_, second_ and third_ signers and signatures, which have
+-- no signed time.
+INSERT INTO rate_signature (rate_id, signer, signature, signed_at)
+SELECT r.id, s.signer, s.signature, s.signed_at
+FROM rate r
+CROSS JOIN LATERAL (
+    SELECT e ->> 'signer'                     AS signer,
+           e ->> 'signature'                  AS signature,
+           (e ->> 'signed_time')::timestamptz AS signed_at
+    FROM jsonb_array_elements(
+        CASE WHEN jsonb_typeof(r.sign_data::jsonb) = 'array' THEN r.sign_data::jsonb ELSE '[]'::jsonb END
+    ) AS e
+    UNION ALL
+    SELECT r.sign_data::jsonb ->> (p || '_Signer'),
+           r.sign_data::jsonb ->> (p || '_Signature'),
+           NULL
+    FROM unnest(ARRAY ['first', 'second', 'third']) AS p
+    WHERE jsonb_typeof(r.sign_data::jsonb) = 'object'
+      AND r.sign_data::jsonb ? (p || '_Signer')
+) AS s
+WHERE s.signer IS NOT NULL AND s.signature IS NOT NULL
+ON CONFLICT (rate_id, signer) DO NOTHING;
+
+ALTER TABLE rate DROP COLUMN sign_data;
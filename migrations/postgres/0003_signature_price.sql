@@ -0,0 +1,5 @@
+-- Every signer signs its own observed price. Before, every signer signed the
+-- price of the rate, so it's carried forward to the existing signatures.
+ALTER TABLE rate_signature ADD COLUMN price numeric;
+
+UPDATE rate_signature s SET price = r.price FROM rate r WHERE r.id = s.rate_id;
@@ -0,0 +1,3 @@
+-- The signing scheme of every signature, so the signatures can be verified
+-- again when a rate is synced. It's not known for the existing signatures.
+ALTER TABLE rate_signature ADD COLUMN scheme text;
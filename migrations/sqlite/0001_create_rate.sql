@@ -0,0 +1,22 @@
+-- Times are stored as unix nanoseconds, and prices as decimal text to keep
+-- their exact value.
+CREATE TABLE rate (
+    id              TEXT    NOT NULL PRIMARY KEY,
+    pair            TEXT    NOT NULL,
+    price           TEXT    NOT NULL,
+    first_signer    TEXT    NOT NULL,
+    lastsigned_time INTEGER NOT NULL,
+    created_time    INTEGER NOT NULL
+);
+
+CREATE INDEX rate_pair_lastsigned_time_idx ON rate (pair, lastsigned_time DESC);
+
+CREATE TABLE rate_signature (
+    rate_id   TEXT NOT NULL REFERENCES rate (id) ON DELETE CASCADE,
+    signer    TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    signed_at INTEGER,
+    PRIMARY KEY (rate_id, signer)
+);
+
+CREATE INDEX rate_signature_signer_idx ON rate_signature (signer);
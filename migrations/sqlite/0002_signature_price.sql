@@ -0,0 +1,5 @@
+-- Every signer signs its own observed price. Before, every signer signed the
+-- price of the rate, so it's carried forward to the existing signatures.
+ALTER TABLE rate_signature ADD COLUMN price TEXT;
+
+UPDATE rate_signature SET price = (SELECT price FROM rate WHERE rate.id = rate_signature.rate_id);
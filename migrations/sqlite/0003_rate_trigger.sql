@@ -0,0 +1,3 @@
+-- The reason the round of the rate was started, e.g. deviation or heartbeat.
+-- Rates stored before were started by the fixed timer.
+ALTER TABLE rate ADD COLUMN trigger TEXT NOT NULL DEFAULT 'interval';